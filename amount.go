@@ -0,0 +1,158 @@
+package sepay
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Amount is an exact monetary amount, stored as an integer number of minor
+// units of its currency (e.g. cents for USD, dong for VND). The number of
+// decimal places is derived from the currency's ISO 4217 exponent.
+//
+// The zero value is a zero amount with no currency.
+type Amount struct {
+	minor    int64
+	currency string
+}
+
+// NewAmount returns an amount of minor units in the given currency, e.g.
+// NewAmount(1999, "USD") is 19.99 USD and NewAmount(50000, "VND") is 50000 VND.
+func NewAmount(minor int64, currency string) Amount {
+	return Amount{minor: minor, currency: strings.ToUpper(currency)}
+}
+
+// VND returns an amount in Vietnamese dong, which has no minor unit.
+func VND(dong int64) Amount {
+	return NewAmount(dong, "VND")
+}
+
+// ParseAmount parses a decimal string such as "19.99" or "50000" as an amount
+// in the given currency. It returns an error if s has more significant
+// decimal places than the currency allows, e.g. fractional VND.
+func ParseAmount(s, currency string) (Amount, error) {
+	currency = strings.ToUpper(currency)
+	scale := currencyScale(currency)
+
+	digits := s
+	negative := strings.HasPrefix(digits, "-")
+	if negative {
+		digits = digits[1:]
+	}
+	whole, frac, _ := strings.Cut(digits, ".")
+	if whole == "" || !isDigits(whole) || !isDigits(frac) {
+		return Amount{}, fmt.Errorf("sepay: invalid amount %q", s)
+	}
+
+	// Trailing zeros beyond the currency's scale carry no value.
+	frac = strings.TrimRight(frac, "0")
+	if len(frac) > scale {
+		return Amount{}, fmt.Errorf("sepay: amount %q has more than %d decimal places for %s", s, scale, currency)
+	}
+	frac += strings.Repeat("0", scale-len(frac))
+
+	minor, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return Amount{}, fmt.Errorf("sepay: invalid amount %q: %w", s, err)
+	}
+	if negative {
+		minor = -minor
+	}
+	return Amount{minor: minor, currency: currency}, nil
+}
+
+// MustParseAmount is like ParseAmount but panics if s cannot be parsed.
+func MustParseAmount(s, currency string) Amount {
+	a, err := ParseAmount(s, currency)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// Minor returns the amount in minor units of its currency.
+func (a Amount) Minor() int64 {
+	return a.minor
+}
+
+// Currency returns the ISO 4217 code of the amount's currency.
+func (a Amount) Currency() string {
+	return a.currency
+}
+
+// IsZero reports whether the amount is zero.
+func (a Amount) IsZero() bool {
+	return a.minor == 0
+}
+
+// String returns the canonical decimal representation of the amount used for
+// checkout signatures: no thousands separators and no trailing fractional
+// zeros (e.g. "10000", "100.5").
+func (a Amount) String() string {
+	scale := currencyScale(a.currency)
+	s := strconv.FormatInt(a.minor, 10)
+	if scale == 0 {
+		return s
+	}
+
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	if len(s) <= scale {
+		s = strings.Repeat("0", scale-len(s)+1) + s
+	}
+	whole, frac := s[:len(s)-scale], strings.TrimRight(s[len(s)-scale:], "0")
+	if frac == "" {
+		return sign + whole
+	}
+	return sign + whole + "." + frac
+}
+
+// MarshalJSON encodes the amount as a JSON number in canonical form.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON decodes a JSON number or numeric string. The amount is
+// interpreted in the receiver's currency if it already has one.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	parsed, err := parseAmountNumber(n, a.currency)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// parseAmountNumber converts an optional JSON number into an amount in the
+// given currency. An empty number yields a zero amount.
+func parseAmountNumber(n json.Number, currency string) (Amount, error) {
+	if n == "" {
+		return NewAmount(0, currency), nil
+	}
+	s := n.String()
+	if strings.ContainsAny(s, "eE") {
+		// Expand exponent notation into a plain decimal.
+		f, err := n.Float64()
+		if err != nil {
+			return Amount{}, err
+		}
+		s = strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return ParseAmount(s, currency)
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
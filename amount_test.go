@@ -0,0 +1,100 @@
+package sepay
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAmount_String(t *testing.T) {
+	tests := []struct {
+		amount   Amount
+		expected string
+	}{
+		{VND(10000), "10000"},
+		{VND(0), "0"},
+		{NewAmount(10050, "USD"), "100.5"},
+		{NewAmount(10000, "USD"), "100"},
+		{NewAmount(5, "USD"), "0.05"},
+		{NewAmount(-1999, "USD"), "-19.99"},
+		{NewAmount(1234, "KWD"), "1.234"},
+		{NewAmount(1000, "jpy"), "1000"},
+		{Amount{}, "0"},
+	}
+	for _, tc := range tests {
+		if got := tc.amount.String(); got != tc.expected {
+			t.Errorf("%#v.String() = %q, want %q", tc.amount, got, tc.expected)
+		}
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		input    string
+		currency string
+		minor    int64
+		wantErr  bool
+	}{
+		{"50000", "VND", 50000, false},
+		{"50000.00", "VND", 50000, false},
+		{"50000.5", "VND", 0, true},
+		{"19.99", "USD", 1999, false},
+		{"19.9", "usd", 1990, false},
+		{"19.999", "USD", 0, true},
+		{"-5", "USD", -500, false},
+		{"0.5", "XYZ", 50, false},
+		{"", "USD", 0, true},
+		{".5", "USD", 0, true},
+		{"1,000", "VND", 0, true},
+		{"1e3", "VND", 0, true},
+		{"99999999999999999999", "VND", 0, true},
+	}
+	for _, tc := range tests {
+		got, err := ParseAmount(tc.input, tc.currency)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("ParseAmount(%q, %q) expected error, got %v", tc.input, tc.currency, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseAmount(%q, %q) unexpected error: %v", tc.input, tc.currency, err)
+			continue
+		}
+		if got.Minor() != tc.minor {
+			t.Errorf("ParseAmount(%q, %q).Minor() = %d, want %d", tc.input, tc.currency, got.Minor(), tc.minor)
+		}
+	}
+}
+
+func TestAmount_JSON(t *testing.T) {
+	t.Run("marshal as number", func(t *testing.T) {
+		data, err := json.Marshal(struct {
+			A Amount `json:"a"`
+		}{NewAmount(12050, "USD")})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(data) != `{"a":120.5}` {
+			t.Errorf("unexpected JSON %s", data)
+		}
+	})
+
+	t.Run("unmarshal string and number", func(t *testing.T) {
+		for _, input := range []string{`"120.50"`, `120.5`, `1.205e2`} {
+			a := NewAmount(0, "USD")
+			if err := json.Unmarshal([]byte(input), &a); err != nil {
+				t.Fatalf("unmarshal %s: %v", input, err)
+			}
+			if a != NewAmount(12050, "USD") {
+				t.Errorf("unmarshal %s = %#v", input, a)
+			}
+		}
+	})
+
+	t.Run("unmarshal rejects fractional VND", func(t *testing.T) {
+		a := VND(0)
+		if err := json.Unmarshal([]byte(`"100.5"`), &a); err == nil {
+			t.Error("expected error for fractional VND")
+		}
+	})
+}
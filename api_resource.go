@@ -9,32 +9,189 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"time"
 )
 
 type apiResource struct {
 	client *Client
 }
 
-func (a *apiResource) authHeader() string {
-	creds := a.client.config.MerchantID + ":" + a.client.config.SecretKey
-	return "Basic " + base64.StdEncoding.EncodeToString([]byte(creds))
+// apiRequest describes a call to the SePay API. The body is kept as bytes so
+// that the request can be replayed when it is retried.
+type apiRequest struct {
+	// operation names the SDK operation, e.g. "Order.All".
+	operation string
+	method    string
+	endpoint  string
+	query     url.Values
+	body      []byte
+	// retrySafe reports whether the request may be sent more than once.
+	retrySafe bool
+	// idempotencyKey, if set, is sent in the Idempotency-Key header.
+	idempotencyKey string
+	// invoiceNumber is the order invoice number the request is for, if any.
+	invoiceNumber string
+	// returnsOrder reports whether the response is an order, whose status
+	// is reported to the client's hooks.
+	returnsOrder bool
+	// uncertain is set once an attempt may have been applied by SePay
+	// without a definite outcome.
+	uncertain bool
 }
 
-func (a *apiResource) doRequest(ctx context.Context, method, endpoint string, query url.Values, body io.Reader) (*Response, error) {
-	rawURL := a.client.baseAPIURL + "/" + endpoint
-	if len(query) > 0 {
-		rawURL += "?" + query.Encode()
+// newAPIRequest returns a request for the given endpoint. GET requests are
+// retry-safe; other requests become so through the RetrySafe option.
+func newAPIRequest(operation, method, endpoint string, opts []RequestOption) *apiRequest {
+	r := &apiRequest{
+		operation: operation,
+		method:    method,
+		endpoint:  endpoint,
+		retrySafe: method == http.MethodGet,
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
 
-	req, err := http.NewRequestWithContext(ctx, method, rawURL, body)
+func (a *apiResource) authHeader(ctx context.Context) (string, error) {
+	secret, err := a.client.config.secretKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	creds := a.client.config.MerchantID + ":" + secret
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(creds)), nil
+}
+
+// doRequest sends r as one operation for the client's hooks, retrying it
+// according to the client's RetryPolicy. Before every attempt it consults
+// the client's CircuitBreaker and waits for its RateLimiter. On failure it
+// returns the response and error of the last attempt.
+func (a *apiResource) doRequest(ctx context.Context, r *apiRequest) (*Response, error) {
+	if len(a.client.hooks) == 0 {
+		resp, _, err := a.attempt(ctx, r)
+		return resp, err
+	}
+
+	start := time.Now()
+	info := r.info(0)
+	ctx = a.client.operationStart(ctx, info)
+	resp, attempts, err := a.attempt(ctx, r)
+	result := OperationResult{
+		Response: resp,
+		Err:      err,
+		Attempts: attempts,
+		Duration: time.Since(start),
+	}
+	if r.returnsOrder && err == nil {
+		result.OrderStatus = peekOrderStatus(resp)
+	}
+	a.client.operationDone(ctx, info, result)
+	return resp, err
+}
+
+// attempt sends r until it succeeds or is not retried, and returns the
+// number of attempts made. Retries stop once the circuit breaker opens, and
+// the outcome of the last attempt sent is returned rather than
+// ErrCircuitOpen.
+func (a *apiResource) attempt(ctx context.Context, r *apiRequest) (*Response, int, error) {
+	policy := a.client.config.Retry
+	limiter := a.client.config.RateLimiter
+	breaker := a.client.config.CircuitBreaker
+	var lastResp *Response
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		if breaker != nil {
+			if err := breaker.allow(r.operation); err != nil {
+				if attempt > 1 {
+					return lastResp, attempt - 1, lastErr
+				}
+				return nil, 0, err
+			}
+		}
+		if limiter != nil {
+			delay, err := limiter.wait(ctx, r.operation)
+			if err != nil {
+				if breaker != nil {
+					breaker.record(r.operation, nil, nil)
+				}
+				return nil, attempt - 1, err
+			}
+			if delay > 0 {
+				a.client.rateLimitWaited(ctx, r.info(attempt), delay)
+			}
+		}
+		resp, err := a.send(ctx, r, attempt)
+		if mayHaveApplied(resp, err) {
+			r.uncertain = true
+		}
+		if breaker != nil {
+			breaker.record(r.operation, resp, err)
+		}
+		if limiter != nil && resp != nil {
+			if resp.StatusCode == http.StatusTooManyRequests {
+				after, _ := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+				limiter.throttle(after)
+			} else {
+				limiter.recover()
+			}
+		}
+		if err == nil {
+			return resp, attempt, nil
+		}
+		delay, retry := policy.retryDelay(ctx, r, attempt, resp, err)
+		if !retry || breaker != nil && breaker.State(r.operation) == CircuitOpen {
+			return resp, attempt, err
+		}
+		lastResp, lastErr = resp, err
+		a.client.retrying(ctx, r.info(attempt), delay, err)
+		if a.client.sleep(ctx, delay) != nil {
+			return resp, attempt, err
+		}
+	}
+}
+
+// doRequestJSON encodes body as the JSON body of r and sends it.
+func (a *apiResource) doRequestJSON(ctx context.Context, r *apiRequest, body any) (*Response, error) {
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("sepay: marshaling request body: %w", err)
+		}
+		r.body = data
+	}
+	return a.doRequest(ctx, r)
+}
+
+// send makes a single attempt at r through the client's middleware. The
+// returned Response is nil only if no response was received.
+func (a *apiResource) send(ctx context.Context, r *apiRequest, attempt int) (*Response, error) {
+	rawURL := a.client.baseAPIURL + "/" + r.endpoint
+	if len(r.query) > 0 {
+		rawURL += "?" + r.query.Encode()
+	}
+
+	auth, err := a.authHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var body io.Reader
+	if r.body != nil {
+		body = bytes.NewReader(r.body)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, rawURL, body)
 	if err != nil {
 		return nil, fmt.Errorf("sepay: creating request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", a.authHeader())
+	req.Header.Set("Authorization", auth)
+	if r.idempotencyKey != "" {
+		req.Header.Set(IdempotencyKeyHeader, r.idempotencyKey)
+	}
 
-	resp, err := a.client.httpClient.Do(req)
+	resp, err := a.client.requestHandler()(req, r.info(attempt))
 	if err != nil {
 		return nil, fmt.Errorf("sepay: executing request: %w", err)
 	}
@@ -45,30 +202,15 @@ func (a *apiResource) doRequest(ctx context.Context, method, endpoint string, qu
 		return nil, fmt.Errorf("sepay: reading response body: %w", err)
 	}
 
-	r := &Response{
+	res := &Response{
 		StatusCode: resp.StatusCode,
 		Header:     resp.Header,
 		Body:       respBody,
 	}
 
 	if resp.StatusCode >= 400 {
-		return r, &APIError{
-			StatusCode: resp.StatusCode,
-			Body:       respBody,
-		}
+		return res, newAPIError(resp.StatusCode, respBody)
 	}
 
-	return r, nil
-}
-
-func (a *apiResource) doRequestJSON(ctx context.Context, method, endpoint string, body any) (*Response, error) {
-	var reader io.Reader
-	if body != nil {
-		data, err := json.Marshal(body)
-		if err != nil {
-			return nil, fmt.Errorf("sepay: marshaling request body: %w", err)
-		}
-		reader = bytes.NewReader(data)
-	}
-	return a.doRequest(ctx, method, endpoint, nil, reader)
+	return res, nil
 }
@@ -1,10 +1,11 @@
 package sepay
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
-	"strconv"
+	"log/slog"
 	"strings"
 )
 
@@ -26,14 +27,14 @@ const (
 )
 
 // OnetimePaymentFields holds the fields for a one-time payment checkout.
+// The checkout currency is taken from OrderAmount.
 type OnetimePaymentFields struct {
 	Operation          Operation
 	PaymentMethod      PaymentMethod
 	OrderInvoiceNumber string
-	OrderAmount        float64
-	Currency           string
+	OrderAmount        Amount
 	OrderDescription   string
-	OrderTaxAmount     *float64
+	OrderTaxAmount     *Amount
 	CustomerID         *string
 	SuccessURL         *string
 	ErrorURL           *string
@@ -47,16 +48,23 @@ type SignedCheckoutFields struct {
 	Operation          Operation     `json:"operation"`
 	PaymentMethod      PaymentMethod `json:"payment_method,omitempty"`
 	OrderInvoiceNumber string        `json:"order_invoice_number"`
-	OrderAmount        float64       `json:"order_amount"`
+	OrderAmount        Amount        `json:"order_amount"`
 	Currency           string        `json:"currency"`
 	OrderDescription   string        `json:"order_description"`
-	OrderTaxAmount     *float64      `json:"order_tax_amount,omitempty"`
+	OrderTaxAmount     *Amount       `json:"order_tax_amount,omitempty"`
 	CustomerID         *string       `json:"customer_id,omitempty"`
 	SuccessURL         *string       `json:"success_url,omitempty"`
 	ErrorURL           *string       `json:"error_url,omitempty"`
 	CancelURL          *string       `json:"cancel_url,omitempty"`
 	CustomData         *string       `json:"custom_data,omitempty"`
-	Signature          string        `json:"signature"`
+
+	AgreementID               *string            `json:"agreement_id,omitempty"`
+	AgreementName             *string            `json:"agreement_name,omitempty"`
+	AgreementType             AgreementType      `json:"agreement_type,omitempty"`
+	AgreementPaymentFrequency AgreementFrequency `json:"agreement_payment_frequency,omitempty"`
+	AgreementAmountPerPayment *Amount            `json:"agreement_amount_per_payment,omitempty"`
+
+	Signature string `json:"signature"`
 }
 
 // FormValues returns the checkout fields as a map suitable for building
@@ -66,7 +74,7 @@ func (f *SignedCheckoutFields) FormValues() map[string]string {
 		"merchant":             f.Merchant,
 		"operation":            string(f.Operation),
 		"order_invoice_number": f.OrderInvoiceNumber,
-		"order_amount":         formatFloat(f.OrderAmount),
+		"order_amount":         f.OrderAmount.String(),
 		"currency":             f.Currency,
 		"order_description":    f.OrderDescription,
 		"signature":            f.Signature,
@@ -75,7 +83,7 @@ func (f *SignedCheckoutFields) FormValues() map[string]string {
 		m["payment_method"] = string(f.PaymentMethod)
 	}
 	if f.OrderTaxAmount != nil {
-		m["order_tax_amount"] = formatFloat(*f.OrderTaxAmount)
+		m["order_tax_amount"] = f.OrderTaxAmount.String()
 	}
 	if f.CustomerID != nil {
 		m["customer_id"] = *f.CustomerID
@@ -92,6 +100,21 @@ func (f *SignedCheckoutFields) FormValues() map[string]string {
 	if f.CustomData != nil {
 		m["custom_data"] = *f.CustomData
 	}
+	if f.AgreementID != nil {
+		m["agreement_id"] = *f.AgreementID
+	}
+	if f.AgreementName != nil {
+		m["agreement_name"] = *f.AgreementName
+	}
+	if f.AgreementType != "" {
+		m["agreement_type"] = string(f.AgreementType)
+	}
+	if f.AgreementPaymentFrequency != "" {
+		m["agreement_payment_frequency"] = string(f.AgreementPaymentFrequency)
+	}
+	if f.AgreementAmountPerPayment != nil {
+		m["agreement_amount_per_payment"] = f.AgreementAmountPerPayment.String()
+	}
 	return m
 }
 
@@ -106,20 +129,41 @@ func (s *CheckoutService) InitCheckoutURL() string {
 }
 
 // InitOneTimePaymentFields signs the given one-time payment fields and returns
-// the complete set of fields including the HMAC-SHA256 signature.
+// the complete set of fields including the HMAC-SHA256 signature. The fields
+// are not validated; use SignOneTimePayment to reject invalid fields before
+// they reach the hosted checkout page. If the secret key cannot be obtained
+// from Config.SecretProvider, Signature is left empty and the error is logged
+// to Config.Logger and passed to the CheckoutSigned hooks.
+//
+// Deprecated: Use SignOneTimePayment, which validates the fields and returns
+// signing errors.
 func (s *CheckoutService) InitOneTimePaymentFields(fields OnetimePaymentFields) *SignedCheckoutFields {
+	ctx := context.Background()
+	signed := s.newSignedFields(fields)
+	if err := s.sign(ctx, signed); err != nil && s.client.config.Logger != nil {
+		s.client.config.Logger.LogAttrs(ctx, slog.LevelError, "sepay: signing checkout fields",
+			slog.String("invoice_number", signed.OrderInvoiceNumber),
+			slog.String("error", err.Error()),
+		)
+	}
+	return signed
+}
+
+// newSignedFields copies the one-time payment fields into an unsigned
+// SignedCheckoutFields, applying defaults.
+func (s *CheckoutService) newSignedFields(fields OnetimePaymentFields) *SignedCheckoutFields {
 	operation := fields.Operation
 	if operation == "" {
 		operation = OperationPurchase
 	}
 
-	signed := &SignedCheckoutFields{
+	return &SignedCheckoutFields{
 		Merchant:           s.client.config.MerchantID,
 		Operation:          operation,
 		PaymentMethod:      fields.PaymentMethod,
 		OrderInvoiceNumber: fields.OrderInvoiceNumber,
 		OrderAmount:        fields.OrderAmount,
-		Currency:           fields.Currency,
+		Currency:           fields.OrderAmount.Currency(),
 		OrderDescription:   fields.OrderDescription,
 		OrderTaxAmount:     fields.OrderTaxAmount,
 		CustomerID:         fields.CustomerID,
@@ -128,34 +172,60 @@ func (s *CheckoutService) InitOneTimePaymentFields(fields OnetimePaymentFields)
 		CancelURL:          fields.CancelURL,
 		CustomData:         fields.CustomData,
 	}
+}
 
-	// Build map of signable fields.
-	signableFields := map[string]string{
-		"merchant":             signed.Merchant,
-		"operation":            string(signed.Operation),
-		"order_amount":         formatFloat(signed.OrderAmount),
-		"currency":             signed.Currency,
-		"order_invoice_number": signed.OrderInvoiceNumber,
-		"order_description":    signed.OrderDescription,
+// sign computes the signature of the given fields with the active secret
+// key. Only the fields listed in signFieldOrder take part in the signature.
+func (s *CheckoutService) sign(ctx context.Context, signed *SignedCheckoutFields) error {
+	secret, err := s.client.config.secretKey(ctx)
+	if err == nil {
+		signed.Signature = signFields(signed.FormValues(), secret)
 	}
-	if signed.PaymentMethod != "" {
-		signableFields["payment_method"] = string(signed.PaymentMethod)
+	s.client.checkoutSigned(ctx, signed, err)
+	return err
+}
+
+// VerifySignature checks the "signature" value of the given checkout fields,
+// e.g. signed fields posted back by a browser, against the active secret key
+// and the unexpired Config.PreviousKeys. It returns the ID of the key that
+// matched, or ErrInvalidSignature if none did.
+func (s *CheckoutService) VerifySignature(values map[string]string) (keyID string, err error) {
+	signature := values["signature"]
+	if signature == "" {
+		return "", ErrInvalidSignature
 	}
-	if signed.CustomerID != nil {
-		signableFields["customer_id"] = *signed.CustomerID
+	keys, err := s.client.config.verificationKeys(context.Background())
+	if err != nil {
+		return "", err
 	}
-	if signed.SuccessURL != nil {
-		signableFields["success_url"] = *signed.SuccessURL
+	keyID, ok := matchKey(keys, s.client.now(), signature, func(secret string) string {
+		return signFields(values, secret)
+	})
+	if !ok {
+		return "", ErrInvalidSignature
 	}
-	if signed.ErrorURL != nil {
-		signableFields["error_url"] = *signed.ErrorURL
+	return keyID, nil
+}
+
+// SignOneTimePayment validates the given one-time payment fields and, if they
+// are valid, signs them like InitOneTimePaymentFields. Invalid fields are
+// reported as ValidationErrors listing every problem found.
+func (s *CheckoutService) SignOneTimePayment(fields OnetimePaymentFields) (*SignedCheckoutFields, error) {
+	return s.SignOneTimePaymentContext(context.Background(), fields)
+}
+
+// SignOneTimePaymentContext is like SignOneTimePayment, passing ctx to the
+// Config.SecretProvider and the client's hooks, e.g. to trace the signing
+// as part of the request being served.
+func (s *CheckoutService) SignOneTimePaymentContext(ctx context.Context, fields OnetimePaymentFields) (*SignedCheckoutFields, error) {
+	if err := fields.Validate(); err != nil {
+		return nil, err
 	}
-	if signed.CancelURL != nil {
-		signableFields["cancel_url"] = *signed.CancelURL
+	signed := s.newSignedFields(fields)
+	if err := s.sign(ctx, signed); err != nil {
+		return nil, err
 	}
-
-	signed.Signature = signFields(signableFields, s.client.config.SecretKey)
-	return signed
+	return signed, nil
 }
 
 // signFieldOrder defines the canonical order for signature computation.
@@ -196,9 +266,3 @@ func signFields(fields map[string]string, secretKey string) string {
 	mac.Write([]byte(strings.Join(parts, ",")))
 	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
 }
-
-// formatFloat formats a float64 without trailing zeros, matching JavaScript's
-// Number.toString() behavior (e.g. 10000 → "10000", 100.5 → "100.5").
-func formatFloat(f float64) string {
-	return strconv.FormatFloat(f, 'f', -1, 64)
-}
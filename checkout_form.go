@@ -0,0 +1,113 @@
+package sepay
+
+import (
+	"html/template"
+	"io"
+	"sort"
+)
+
+// defaultButtonText is the submit button label used when FormOptions does not
+// provide one.
+const defaultButtonText = "Thanh toán"
+
+// defaultFormTemplate renders a checkout form. When auto-submitting, the
+// script sits right after the form so it can find it without an element ID,
+// and the <noscript> button covers browsers with scripting disabled.
+var defaultFormTemplate = template.Must(template.New("sepay-checkout-form").Parse(
+	`<form action="{{.Action}}" method="POST">
+{{- range .Fields}}
+  <input type="hidden" name="{{.Name}}" value="{{.Value}}">
+{{- end}}
+{{- if .AutoSubmit}}
+  <noscript><button type="submit">{{.ButtonText}}</button></noscript>
+{{- else}}
+  <button type="submit">{{.ButtonText}}</button>
+{{- end}}
+</form>
+{{- if .AutoSubmit}}
+<script{{with .Nonce}} nonce="{{.}}"{{end}}>document.currentScript.previousElementSibling.submit();</script>
+{{- end}}
+`))
+
+// FormOptions customizes the HTML form rendered by CheckoutService.RenderForm.
+type FormOptions struct {
+	// AutoSubmit adds a script that submits the form as soon as it loads.
+	AutoSubmit bool
+	// Nonce is the Content-Security-Policy nonce set on the auto-submit
+	// script.
+	Nonce string
+	// ButtonText is the label of the submit button. Defaults to
+	// "Thanh toán".
+	ButtonText string
+	// Template replaces the default form template. It is executed with a
+	// FormData value.
+	Template *template.Template
+}
+
+// FormData is the data passed to the template rendering a checkout form.
+type FormData struct {
+	Action     string
+	Fields     []FormField
+	AutoSubmit bool
+	Nonce      string
+	ButtonText string
+}
+
+// FormField is a single hidden input of a checkout form.
+type FormField struct {
+	Name  string
+	Value string
+}
+
+// FormFields returns the checkout fields in a deterministic order: the fields
+// covered by the signature in signing order, then the remaining fields sorted
+// by name, and the signature last.
+func (f *SignedCheckoutFields) FormFields() []FormField {
+	values := f.FormValues()
+	fields := make([]FormField, 0, len(values))
+
+	for _, name := range signFieldOrder {
+		if value, ok := values[name]; ok {
+			fields = append(fields, FormField{Name: name, Value: value})
+			delete(values, name)
+		}
+	}
+
+	signature := values["signature"]
+	delete(values, "signature")
+	rest := make([]string, 0, len(values))
+	for name := range values {
+		rest = append(rest, name)
+	}
+	sort.Strings(rest)
+	for _, name := range rest {
+		fields = append(fields, FormField{Name: name, Value: values[name]})
+	}
+
+	return append(fields, FormField{Name: "signature", Value: signature})
+}
+
+// RenderForm writes an HTML form that posts the signed fields to the checkout
+// URL. All values are escaped by html/template. A nil opts renders a form with
+// a visible submit button.
+func (s *CheckoutService) RenderForm(w io.Writer, signed *SignedCheckoutFields, opts *FormOptions) error {
+	if opts == nil {
+		opts = &FormOptions{}
+	}
+	data := FormData{
+		Action:     s.InitCheckoutURL(),
+		Fields:     signed.FormFields(),
+		AutoSubmit: opts.AutoSubmit,
+		Nonce:      opts.Nonce,
+		ButtonText: opts.ButtonText,
+	}
+	if data.ButtonText == "" {
+		data.ButtonText = defaultButtonText
+	}
+
+	tmpl := opts.Template
+	if tmpl == nil {
+		tmpl = defaultFormTemplate
+	}
+	return tmpl.Execute(w, data)
+}
@@ -0,0 +1,120 @@
+package sepay
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+)
+
+func newFormTestClient(t *testing.T) *Client {
+	t.Helper()
+	c, err := NewClient(Config{
+		Env:        Sandbox,
+		MerchantID: "test_merchant",
+		SecretKey:  "test_secret",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return c
+}
+
+func TestSignedCheckoutFields_FormFields(t *testing.T) {
+	c := newFormTestClient(t)
+	signed := c.Checkout.InitOneTimePaymentFields(OnetimePaymentFields{
+		PaymentMethod:      BankTransfer,
+		OrderInvoiceNumber: "INV-001",
+		OrderAmount:        VND(50000),
+		OrderDescription:   "Test order",
+		OrderTaxAmount:     AmountPtr(VND(5000)),
+		SuccessURL:         String("https://example.com/success"),
+		CustomData:         String("extra"),
+	})
+
+	var names []string
+	for _, f := range signed.FormFields() {
+		names = append(names, f.Name)
+	}
+	expected := "merchant,operation,payment_method,order_amount,currency,order_invoice_number," +
+		"order_description,success_url,custom_data,order_tax_amount,signature"
+	if got := strings.Join(names, ","); got != expected {
+		t.Errorf("unexpected field order:\n  expected: %s\n  got:      %s", expected, got)
+	}
+}
+
+func TestCheckoutService_RenderForm(t *testing.T) {
+	c := newFormTestClient(t)
+	signed := c.Checkout.InitOneTimePaymentFields(OnetimePaymentFields{
+		OrderInvoiceNumber: "INV-001",
+		OrderAmount:        VND(50000),
+		OrderDescription:   `Tom & Jerry "special" <order>`,
+	})
+
+	t.Run("default form", func(t *testing.T) {
+		var b strings.Builder
+		if err := c.Checkout.RenderForm(&b, signed, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		out := b.String()
+
+		if !strings.Contains(out, `<form action="https://pay-sandbox.sepay.vn/v1/checkout/init" method="POST">`) {
+			t.Errorf("expected form action, got:\n%s", out)
+		}
+		if !strings.Contains(out, `value="Tom &amp; Jerry &#34;special&#34; &lt;order&gt;"`) {
+			t.Errorf("expected escaped description, got:\n%s", out)
+		}
+		if !strings.Contains(out, `<button type="submit">Thanh toán</button>`) {
+			t.Errorf("expected visible submit button, got:\n%s", out)
+		}
+		if strings.Contains(out, "<script") || strings.Contains(out, "<noscript>") {
+			t.Errorf("expected no script, got:\n%s", out)
+		}
+
+		var again strings.Builder
+		c.Checkout.RenderForm(&again, signed, nil)
+		if again.String() != out {
+			t.Error("expected deterministic output")
+		}
+	})
+
+	t.Run("auto-submit with nonce", func(t *testing.T) {
+		var b strings.Builder
+		err := c.Checkout.RenderForm(&b, signed, &FormOptions{
+			AutoSubmit: true,
+			Nonce:      `abc"123`,
+			ButtonText: "Continue",
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		out := b.String()
+
+		if !strings.Contains(out, `<script nonce="abc&#34;123">`) {
+			t.Errorf("expected escaped nonce on script, got:\n%s", out)
+		}
+		if !strings.Contains(out, `<noscript><button type="submit">Continue</button></noscript>`) {
+			t.Errorf("expected noscript fallback button, got:\n%s", out)
+		}
+	})
+
+	t.Run("auto-submit without nonce", func(t *testing.T) {
+		var b strings.Builder
+		c.Checkout.RenderForm(&b, signed, &FormOptions{AutoSubmit: true})
+		if !strings.Contains(b.String(), "<script>") {
+			t.Errorf("expected script without nonce attribute, got:\n%s", b.String())
+		}
+	})
+
+	t.Run("custom template", func(t *testing.T) {
+		tmpl := template.Must(template.New("custom").Parse(
+			`{{.Action}}|{{len .Fields}}|{{.ButtonText}}`))
+		var b strings.Builder
+		if err := c.Checkout.RenderForm(&b, signed, &FormOptions{Template: tmpl}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := "https://pay-sandbox.sepay.vn/v1/checkout/init|7|Thanh toán"
+		if b.String() != expected {
+			t.Errorf("expected %q, got %q", expected, b.String())
+		}
+	})
+}
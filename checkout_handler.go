@@ -0,0 +1,183 @@
+package sepay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// OrderProvider supplies the checkout details of the merchant's own orders to
+// a CheckoutHandler.
+type OrderProvider interface {
+	// CheckoutFields returns the payment fields (amount, description,
+	// customer, callback URLs, ...) of the order with the given invoice
+	// number. Return an error matching ErrOrderNotFound if there is no such
+	// order. OrderInvoiceNumber defaults to invoiceNumber if left empty.
+	CheckoutFields(ctx context.Context, invoiceNumber string) (OnetimePaymentFields, error)
+}
+
+// OrderProviderFunc adapts an ordinary function to the OrderProvider
+// interface.
+type OrderProviderFunc func(ctx context.Context, invoiceNumber string) (OnetimePaymentFields, error)
+
+// CheckoutFields calls f(ctx, invoiceNumber).
+func (f OrderProviderFunc) CheckoutFields(ctx context.Context, invoiceNumber string) (OnetimePaymentFields, error) {
+	return f(ctx, invoiceNumber)
+}
+
+// HandlerError is passed to the ErrorHandler of the SDK's HTTP handlers. It
+// carries the HTTP status code the handler would respond with.
+type HandlerError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *HandlerError) Error() string {
+	return fmt.Sprintf("sepay: handler error: status %d: %v", e.StatusCode, e.Err)
+}
+
+func (e *HandlerError) Unwrap() error {
+	return e.Err
+}
+
+// CheckoutHandler is an http.Handler that starts a checkout for one of the
+// merchant's orders. It reads the invoice number from the request, asks the
+// OrderProvider for the order, signs the fields with
+// SignOneTimePaymentContext, and responds with an auto-submitting HTML form,
+// or with the signed fields as JSON when the client accepts
+// application/json.
+type CheckoutHandler struct {
+	Checkout *CheckoutService
+	Provider OrderProvider
+
+	// InvoiceNumber extracts the invoice number from the request. Defaults
+	// to the "invoice" query or form value.
+	InvoiceNumber func(r *http.Request) string
+	// Methods lists the allowed HTTP methods. Defaults to GET and POST.
+	Methods []string
+	// VerifyCSRF, if set, is called before the order is looked up. A non-nil
+	// error rejects the request with 403 Forbidden.
+	VerifyCSRF func(r *http.Request) error
+	// FormOptions returns the options used to render the HTML form, e.g. to
+	// set the request's CSP nonce. Defaults to an auto-submitting form.
+	FormOptions func(r *http.Request) *FormOptions
+	// ErrorHandler, if set, writes the response for a failed request. The
+	// error is a *HandlerError. Defaults to a plain-text status response.
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// NewCheckoutHandler returns a CheckoutHandler with default settings.
+func NewCheckoutHandler(checkout *CheckoutService, provider OrderProvider) *CheckoutHandler {
+	return &CheckoutHandler{Checkout: checkout, Provider: provider}
+}
+
+// checkoutResponse is the JSON body returned to clients that accept JSON.
+type checkoutResponse struct {
+	CheckoutURL string            `json:"checkout_url"`
+	Fields      map[string]string `json:"fields"`
+}
+
+func (h *CheckoutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	methods := h.Methods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodPost}
+	}
+	if !containsMethod(methods, r.Method) {
+		w.Header().Set("Allow", strings.Join(methods, ", "))
+		h.fail(w, r, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	if h.VerifyCSRF != nil {
+		if err := h.VerifyCSRF(r); err != nil {
+			h.fail(w, r, http.StatusForbidden, err)
+			return
+		}
+	}
+
+	invoiceNumber := h.invoiceNumber(r)
+	if invoiceNumber == "" {
+		h.fail(w, r, http.StatusBadRequest, errors.New("missing invoice number"))
+		return
+	}
+
+	fields, err := h.Provider.CheckoutFields(r.Context(), invoiceNumber)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrOrderNotFound) {
+			status = http.StatusNotFound
+		}
+		h.fail(w, r, status, err)
+		return
+	}
+	if fields.OrderInvoiceNumber == "" {
+		fields.OrderInvoiceNumber = invoiceNumber
+	}
+
+	signed, err := h.Checkout.SignOneTimePaymentContext(r.Context(), fields)
+	if err != nil {
+		h.fail(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Signed fields are specific to this request and must not be cached.
+	w.Header().Set("Cache-Control", "no-store")
+
+	if acceptsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(checkoutResponse{
+			CheckoutURL: h.Checkout.InitCheckoutURL(),
+			Fields:      signed.FormValues(),
+		})
+		return
+	}
+
+	opts := &FormOptions{AutoSubmit: true}
+	if h.FormOptions != nil {
+		opts = h.FormOptions(r)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, "<!DOCTYPE html>\n<html>\n<head><meta charset=\"utf-8\"></head>\n<body>\n")
+	if err := h.Checkout.RenderForm(w, signed, opts); err != nil {
+		return
+	}
+	fmt.Fprint(w, "</body>\n</html>\n")
+}
+
+func (h *CheckoutHandler) invoiceNumber(r *http.Request) string {
+	if h.InvoiceNumber != nil {
+		return h.InvoiceNumber(r)
+	}
+	return r.FormValue("invoice")
+}
+
+func (h *CheckoutHandler) fail(w http.ResponseWriter, r *http.Request, status int, err error) {
+	handleError(w, r, h.ErrorHandler, &HandlerError{StatusCode: status, Err: err})
+}
+
+// handleError passes err to the custom error handler if there is one, or
+// writes a plain-text status response otherwise.
+func handleError(w http.ResponseWriter, r *http.Request, custom func(http.ResponseWriter, *http.Request, error), err *HandlerError) {
+	if custom != nil {
+		custom(w, r, err)
+		return
+	}
+	http.Error(w, http.StatusText(err.StatusCode), err.StatusCode)
+}
+
+func containsMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsJSON reports whether the client prefers a JSON response.
+func acceptsJSON(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
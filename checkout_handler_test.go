@@ -0,0 +1,152 @@
+package sepay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestCheckoutHandler(t *testing.T) *CheckoutHandler {
+	t.Helper()
+	c := newFormTestClient(t)
+	return NewCheckoutHandler(c.Checkout, OrderProviderFunc(func(ctx context.Context, invoiceNumber string) (OnetimePaymentFields, error) {
+		switch invoiceNumber {
+		case "INV-001":
+			return OnetimePaymentFields{
+				PaymentMethod:    BankTransfer,
+				OrderAmount:      VND(50000),
+				OrderDescription: "Test order",
+				CustomerID:       String("CUST-001"),
+			}, nil
+		case "INV-BAD":
+			return OnetimePaymentFields{OrderAmount: VND(-1)}, nil
+		case "INV-ERR":
+			return OnetimePaymentFields{}, errors.New("database unavailable")
+		}
+		return OnetimePaymentFields{}, ErrOrderNotFound
+	}))
+}
+
+func TestCheckoutHandler(t *testing.T) {
+	t.Run("renders auto-submit form", func(t *testing.T) {
+		h := newTestCheckoutHandler(t)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/checkout?invoice=INV-001", nil))
+
+		if rec.Code != 200 {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+			t.Errorf("unexpected content type %q", ct)
+		}
+		if rec.Header().Get("Cache-Control") != "no-store" {
+			t.Error("expected Cache-Control: no-store")
+		}
+		body := rec.Body.String()
+		for _, want := range []string{
+			`<input type="hidden" name="order_invoice_number" value="INV-001">`,
+			`<input type="hidden" name="customer_id" value="CUST-001">`,
+			`<noscript>`,
+			`<script>`,
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("expected body to contain %q, got:\n%s", want, body)
+			}
+		}
+	})
+
+	t.Run("json for SPA clients", func(t *testing.T) {
+		h := newTestCheckoutHandler(t)
+		req := httptest.NewRequest("POST", "/checkout", strings.NewReader("invoice=INV-001"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", "application/json")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != 200 {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		var resp checkoutResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.CheckoutURL != "https://pay-sandbox.sepay.vn/v1/checkout/init" {
+			t.Errorf("unexpected checkout URL %q", resp.CheckoutURL)
+		}
+		if resp.Fields["order_amount"] != "50000" || resp.Fields["signature"] == "" {
+			t.Errorf("unexpected fields %v", resp.Fields)
+		}
+	})
+
+	t.Run("custom invoice extractor and form options", func(t *testing.T) {
+		h := newTestCheckoutHandler(t)
+		h.InvoiceNumber = func(r *http.Request) string {
+			return strings.TrimPrefix(r.URL.Path, "/pay/")
+		}
+		h.FormOptions = func(r *http.Request) *FormOptions {
+			return &FormOptions{AutoSubmit: true, Nonce: "n0nce"}
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/pay/INV-001", nil))
+
+		if rec.Code != 200 {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		if !strings.Contains(rec.Body.String(), `<script nonce="n0nce">`) {
+			t.Errorf("expected nonce on script, got:\n%s", rec.Body.String())
+		}
+	})
+
+	statusTests := []struct {
+		name   string
+		method string
+		target string
+		csrf   error
+		status int
+	}{
+		{"method not allowed", "DELETE", "/checkout?invoice=INV-001", nil, 405},
+		{"csrf rejected", "POST", "/checkout?invoice=INV-001", errors.New("bad token"), 403},
+		{"missing invoice", "GET", "/checkout", nil, 400},
+		{"unknown order", "GET", "/checkout?invoice=INV-404", nil, 404},
+		{"provider error", "GET", "/checkout?invoice=INV-ERR", nil, 500},
+		{"invalid fields", "GET", "/checkout?invoice=INV-BAD", nil, 500},
+	}
+	for _, tc := range statusTests {
+		t.Run(tc.name, func(t *testing.T) {
+			h := newTestCheckoutHandler(t)
+			h.VerifyCSRF = func(r *http.Request) error { return tc.csrf }
+			var handled *HandlerError
+			h.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+				errors.As(err, &handled)
+				w.WriteHeader(handled.StatusCode)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.target, nil))
+
+			if rec.Code != tc.status {
+				t.Errorf("expected status %d, got %d", tc.status, rec.Code)
+			}
+			if handled == nil || handled.StatusCode != tc.status {
+				t.Errorf("expected error handler to receive status %d, got %+v", tc.status, handled)
+			}
+		})
+	}
+
+	t.Run("allow header", func(t *testing.T) {
+		h := newTestCheckoutHandler(t)
+		h.Methods = []string{http.MethodPost}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/checkout?invoice=INV-001", nil))
+
+		if rec.Code != 405 {
+			t.Errorf("expected status 405, got %d", rec.Code)
+		}
+		if rec.Header().Get("Allow") != "POST" {
+			t.Errorf("expected Allow: POST, got %q", rec.Header().Get("Allow"))
+		}
+	})
+}
@@ -0,0 +1,161 @@
+package sepay
+
+import "context"
+
+// AgreementType represents the type of a recurring payment agreement.
+type AgreementType string
+
+const (
+	// AgreementTypeRecurring charges a fixed amount on a fixed schedule.
+	AgreementTypeRecurring AgreementType = "RECURRING"
+	// AgreementTypeInstallment splits a purchase into scheduled payments.
+	AgreementTypeInstallment AgreementType = "INSTALLMENT"
+	// AgreementTypeUnscheduled charges varying amounts when the merchant
+	// initiates a payment.
+	AgreementTypeUnscheduled AgreementType = "UNSCHEDULED"
+)
+
+// Valid reports whether t is a known agreement type.
+func (t AgreementType) Valid() bool {
+	switch t {
+	case AgreementTypeRecurring, AgreementTypeInstallment, AgreementTypeUnscheduled:
+		return true
+	}
+	return false
+}
+
+// AgreementFrequency represents how often payments are taken under an
+// agreement.
+type AgreementFrequency string
+
+const (
+	FrequencyDaily     AgreementFrequency = "DAILY"
+	FrequencyWeekly    AgreementFrequency = "WEEKLY"
+	FrequencyMonthly   AgreementFrequency = "MONTHLY"
+	FrequencyQuarterly AgreementFrequency = "QUARTERLY"
+	FrequencyYearly    AgreementFrequency = "YEARLY"
+)
+
+// Valid reports whether f is a known payment frequency.
+func (f AgreementFrequency) Valid() bool {
+	switch f {
+	case FrequencyDaily, FrequencyWeekly, FrequencyMonthly, FrequencyQuarterly, FrequencyYearly:
+		return true
+	}
+	return false
+}
+
+// AgreementFields describes the recurring payment agreement the customer
+// consents to during checkout.
+type AgreementFields struct {
+	AgreementID   string
+	AgreementName string
+	AgreementType AgreementType
+	// PaymentFrequency is required unless AgreementType is
+	// AgreementTypeUnscheduled.
+	PaymentFrequency AgreementFrequency
+	// AmountPerPayment is the amount of each subsequent payment, if fixed.
+	AmountPerPayment *Amount
+}
+
+// RecurringPaymentFields holds the fields for a card checkout that sets up a
+// recurring payment agreement. The first payment is OrderAmount; the
+// operation is always PURCHASE and the payment method always CARD.
+type RecurringPaymentFields struct {
+	OrderInvoiceNumber string
+	OrderAmount        Amount
+	OrderDescription   string
+	OrderTaxAmount     *Amount
+	CustomerID         string
+	SuccessURL         *string
+	ErrorURL           *string
+	CancelURL          *string
+	CustomData         *string
+	Agreement          AgreementFields
+}
+
+// onetime returns the order part of the fields.
+func (f *RecurringPaymentFields) onetime() OnetimePaymentFields {
+	return OnetimePaymentFields{
+		Operation:          OperationPurchase,
+		PaymentMethod:      Card,
+		OrderInvoiceNumber: f.OrderInvoiceNumber,
+		OrderAmount:        f.OrderAmount,
+		OrderDescription:   f.OrderDescription,
+		OrderTaxAmount:     f.OrderTaxAmount,
+		CustomerID:         String(f.CustomerID),
+		SuccessURL:         f.SuccessURL,
+		ErrorURL:           f.ErrorURL,
+		CancelURL:          f.CancelURL,
+		CustomData:         f.CustomData,
+	}
+}
+
+// Validate checks the fields before signing and returns ValidationErrors
+// listing every problem found, or nil.
+func (f *RecurringPaymentFields) Validate() error {
+	var errs ValidationErrors
+	onetime := f.onetime()
+	if err := onetime.Validate(); err != nil {
+		errs = append(errs, err.(ValidationErrors)...)
+	}
+	if f.CustomerID == "" {
+		errs.add("CustomerID", "must not be empty")
+	}
+
+	a := f.Agreement
+	if a.AgreementID == "" {
+		errs.add("Agreement.AgreementID", "must not be empty")
+	} else if len(a.AgreementID) > maxInvoiceNumberLength {
+		errs.add("Agreement.AgreementID", "must be at most %d characters", maxInvoiceNumberLength)
+	}
+	if a.AgreementName == "" {
+		errs.add("Agreement.AgreementName", "must not be empty")
+	}
+	if !a.AgreementType.Valid() {
+		errs.add("Agreement.AgreementType", "unknown agreement type %q", a.AgreementType)
+	}
+	switch {
+	case a.PaymentFrequency == "" && a.AgreementType != AgreementTypeUnscheduled:
+		errs.add("Agreement.PaymentFrequency", "must not be empty for %s agreements", a.AgreementType)
+	case a.PaymentFrequency != "" && !a.PaymentFrequency.Valid():
+		errs.add("Agreement.PaymentFrequency", "unknown payment frequency %q", a.PaymentFrequency)
+	}
+	if amount := a.AmountPerPayment; amount != nil {
+		switch {
+		case amount.Currency() != f.OrderAmount.Currency():
+			errs.add("Agreement.AmountPerPayment", "currency %q does not match order currency %q", amount.Currency(), f.OrderAmount.Currency())
+		case amount.Minor() <= 0:
+			errs.add("Agreement.AmountPerPayment", "must be positive")
+		}
+	}
+
+	return errs.err()
+}
+
+// InitRecurringPaymentFields validates the given recurring payment fields and
+// returns the complete set of checkout fields, including the agreement, signed
+// with HMAC-SHA256. Invalid fields are reported as ValidationErrors.
+func (s *CheckoutService) InitRecurringPaymentFields(fields RecurringPaymentFields) (*SignedCheckoutFields, error) {
+	return s.InitRecurringPaymentFieldsContext(context.Background(), fields)
+}
+
+// InitRecurringPaymentFieldsContext is like InitRecurringPaymentFields,
+// passing ctx to the Config.SecretProvider and the client's hooks.
+func (s *CheckoutService) InitRecurringPaymentFieldsContext(ctx context.Context, fields RecurringPaymentFields) (*SignedCheckoutFields, error) {
+	if err := fields.Validate(); err != nil {
+		return nil, err
+	}
+
+	signed := s.newSignedFields(fields.onetime())
+	a := fields.Agreement
+	signed.AgreementID = String(a.AgreementID)
+	signed.AgreementName = String(a.AgreementName)
+	signed.AgreementType = a.AgreementType
+	signed.AgreementPaymentFrequency = a.PaymentFrequency
+	signed.AgreementAmountPerPayment = a.AmountPerPayment
+	if err := s.sign(ctx, signed); err != nil {
+		return nil, err
+	}
+	return signed, nil
+}
@@ -0,0 +1,149 @@
+package sepay
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func validRecurringPaymentFields() RecurringPaymentFields {
+	return RecurringPaymentFields{
+		OrderInvoiceNumber: "SUB-001",
+		OrderAmount:        VND(99000),
+		OrderDescription:   "Premium plan",
+		CustomerID:         "CUST-001",
+		SuccessURL:         String("https://example.com/success"),
+		Agreement: AgreementFields{
+			AgreementID:      "AGR-001",
+			AgreementName:    "Premium monthly",
+			AgreementType:    AgreementTypeRecurring,
+			PaymentFrequency: FrequencyMonthly,
+			AmountPerPayment: AmountPtr(VND(99000)),
+		},
+	}
+}
+
+func TestCheckoutService_InitRecurringPaymentFields(t *testing.T) {
+	c, _ := NewClient(Config{
+		Env:        Sandbox,
+		MerchantID: "test_merchant",
+		SecretKey:  "test_secret",
+	})
+
+	t.Run("signs agreement fields", func(t *testing.T) {
+		signed, err := c.Checkout.InitRecurringPaymentFields(validRecurringPaymentFields())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if signed.Operation != OperationPurchase {
+			t.Errorf("expected operation %q, got %q", OperationPurchase, signed.Operation)
+		}
+		if signed.PaymentMethod != Card {
+			t.Errorf("expected payment method %q, got %q", Card, signed.PaymentMethod)
+		}
+
+		data := strings.Join([]string{
+			"merchant=test_merchant",
+			"operation=PURCHASE",
+			"payment_method=CARD",
+			"order_amount=99000",
+			"currency=VND",
+			"order_invoice_number=SUB-001",
+			"order_description=Premium plan",
+			"customer_id=CUST-001",
+			"agreement_id=AGR-001",
+			"agreement_name=Premium monthly",
+			"agreement_type=RECURRING",
+			"agreement_payment_frequency=MONTHLY",
+			"agreement_amount_per_payment=99000",
+			"success_url=https://example.com/success",
+		}, ",")
+		mac := hmac.New(sha256.New, []byte("test_secret"))
+		mac.Write([]byte(data))
+		expectedSig := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+		if signed.Signature != expectedSig {
+			t.Errorf("signature mismatch:\n  expected: %s\n  got:      %s", expectedSig, signed.Signature)
+		}
+
+		form := signed.FormValues()
+		expected := map[string]string{
+			"agreement_id":                 "AGR-001",
+			"agreement_name":               "Premium monthly",
+			"agreement_type":               "RECURRING",
+			"agreement_payment_frequency":  "MONTHLY",
+			"agreement_amount_per_payment": "99000",
+		}
+		for k, v := range expected {
+			if form[k] != v {
+				t.Errorf("form[%q] = %q, want %q", k, form[k], v)
+			}
+		}
+	})
+
+	t.Run("unscheduled agreement without frequency", func(t *testing.T) {
+		fields := validRecurringPaymentFields()
+		fields.Agreement.AgreementType = AgreementTypeUnscheduled
+		fields.Agreement.PaymentFrequency = ""
+		fields.Agreement.AmountPerPayment = nil
+
+		signed, err := c.Checkout.InitRecurringPaymentFields(fields)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		form := signed.FormValues()
+		if _, ok := form["agreement_payment_frequency"]; ok {
+			t.Error("expected agreement_payment_frequency to be absent")
+		}
+		if _, ok := form["agreement_amount_per_payment"]; ok {
+			t.Error("expected agreement_amount_per_payment to be absent")
+		}
+	})
+
+	t.Run("context", func(t *testing.T) {
+		c, _ := NewClient(Config{Env: Sandbox, MerchantID: "test_merchant", SecretKey: "test_secret"})
+		var got any
+		c.AddHooks(Hooks{
+			CheckoutSigned: func(ctx context.Context, fields *SignedCheckoutFields, err error) {
+				got = ctx.Value(hookCtxKey{})
+			},
+		})
+		ctx := context.WithValue(context.Background(), hookCtxKey{}, "request")
+		if _, err := c.Checkout.InitRecurringPaymentFieldsContext(ctx, validRecurringPaymentFields()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != "request" {
+			t.Errorf("expected the caller's context to reach the hooks, got %v", got)
+		}
+	})
+
+	t.Run("invalid fields", func(t *testing.T) {
+		fields := validRecurringPaymentFields()
+		fields.CustomerID = ""
+		fields.Agreement.AgreementID = ""
+		fields.Agreement.AgreementType = "WEEKLY"
+		fields.Agreement.PaymentFrequency = "FORTNIGHTLY"
+		fields.Agreement.AmountPerPayment = AmountPtr(NewAmount(500, "USD"))
+
+		signed, err := c.Checkout.InitRecurringPaymentFields(fields)
+		if signed != nil {
+			t.Error("expected nil signed fields")
+		}
+		var errs ValidationErrors
+		if !errors.As(err, &errs) {
+			t.Fatalf("expected ValidationErrors, got %T", err)
+		}
+		var got []string
+		for _, e := range errs {
+			got = append(got, e.Field)
+		}
+		want := "CustomerID,Agreement.AgreementID,Agreement.AgreementType,Agreement.PaymentFrequency,Agreement.AmountPerPayment"
+		if strings.Join(got, ",") != want {
+			t.Errorf("expected fields %s, got %v", want, got)
+		}
+	})
+}
@@ -44,8 +44,7 @@ func TestCheckoutService_InitOneTimePaymentFields(t *testing.T) {
 
 		signed := c.Checkout.InitOneTimePaymentFields(OnetimePaymentFields{
 			OrderInvoiceNumber: "INV-001",
-			OrderAmount:        50000,
-			Currency:           "VND",
+			OrderAmount:        VND(50000),
 			OrderDescription:   "Test order",
 		})
 
@@ -88,10 +87,9 @@ func TestCheckoutService_InitOneTimePaymentFields(t *testing.T) {
 			Operation:          OperationPurchase,
 			PaymentMethod:      BankTransfer,
 			OrderInvoiceNumber: "INV-002",
-			OrderAmount:        100000.5,
-			Currency:           "VND",
+			OrderAmount:        MustParseAmount("100000.50", "USD"),
 			OrderDescription:   "Full test",
-			OrderTaxAmount:     Float64(1000),
+			OrderTaxAmount:     AmountPtr(NewAmount(100000, "USD")),
 			CustomerID:         String("CUST-001"),
 			SuccessURL:         String("https://example.com/success"),
 			ErrorURL:           String("https://example.com/error"),
@@ -102,6 +100,9 @@ func TestCheckoutService_InitOneTimePaymentFields(t *testing.T) {
 		if signed.PaymentMethod != BankTransfer {
 			t.Errorf("expected payment_method %q, got %q", BankTransfer, signed.PaymentMethod)
 		}
+		if signed.Currency != "USD" {
+			t.Errorf("expected currency %q, got %q", "USD", signed.Currency)
+		}
 		if signed.CustomerID == nil || *signed.CustomerID != "CUST-001" {
 			t.Errorf("expected customer_id %q", "CUST-001")
 		}
@@ -115,7 +116,7 @@ func TestCheckoutService_InitOneTimePaymentFields(t *testing.T) {
 			"operation=PURCHASE",
 			"payment_method=BANK_TRANSFER",
 			"order_amount=100000.5",
-			"currency=VND",
+			"currency=USD",
 			"order_invoice_number=INV-002",
 			"order_description=Full test",
 			"customer_id=CUST-001",
@@ -142,8 +143,7 @@ func TestSignedCheckoutFields_FormValues(t *testing.T) {
 
 	signed := c.Checkout.InitOneTimePaymentFields(OnetimePaymentFields{
 		OrderInvoiceNumber: "INV-001",
-		OrderAmount:        50000,
-		Currency:           "VND",
+		OrderAmount:        VND(50000),
 		OrderDescription:   "Test order",
 		CustomerID:         String("CUST-001"),
 	})
@@ -171,22 +171,3 @@ func TestSignedCheckoutFields_FormValues(t *testing.T) {
 		t.Error("expected payment_method to be absent")
 	}
 }
-
-func TestFormatFloat(t *testing.T) {
-	tests := []struct {
-		input    float64
-		expected string
-	}{
-		{10000, "10000"},
-		{100.5, "100.5"},
-		{0, "0"},
-		{1.23456, "1.23456"},
-		{100000.00, "100000"},
-	}
-	for _, tc := range tests {
-		got := formatFloat(tc.input)
-		if got != tc.expected {
-			t.Errorf("formatFloat(%v) = %q, want %q", tc.input, got, tc.expected)
-		}
-	}
-}
@@ -0,0 +1,114 @@
+package sepay
+
+import (
+	"net/url"
+)
+
+// maxInvoiceNumberLength is the longest order invoice number SePay accepts.
+const maxInvoiceNumberLength = 50
+
+// Validate checks the fields before signing and returns ValidationErrors
+// listing every problem found, or nil.
+func (f *OnetimePaymentFields) Validate() error {
+	var errs ValidationErrors
+
+	operation := f.Operation
+	if operation == "" {
+		operation = OperationPurchase
+	}
+	if !operation.Valid() {
+		errs.add("Operation", "unknown operation %q", f.Operation)
+	}
+	if f.PaymentMethod != "" && !f.PaymentMethod.Valid() {
+		errs.add("PaymentMethod", "unknown payment method %q", f.PaymentMethod)
+	} else if operation == OperationVerify && f.PaymentMethod != Card {
+		errs.add("PaymentMethod", "must be %s for %s", Card, OperationVerify)
+	}
+
+	validateInvoiceNumber(&errs, f.OrderInvoiceNumber)
+
+	currency := f.OrderAmount.Currency()
+	switch {
+	case currency == "":
+		errs.add("OrderAmount", "currency is required")
+	case !validCurrency(currency):
+		errs.add("OrderAmount", "unknown currency %q", currency)
+	}
+	if operation == OperationVerify {
+		if f.OrderAmount.Minor() < 0 {
+			errs.add("OrderAmount", "must not be negative")
+		}
+	} else if f.OrderAmount.Minor() <= 0 {
+		errs.add("OrderAmount", "must be positive")
+	}
+
+	if tax := f.OrderTaxAmount; tax != nil {
+		switch {
+		case tax.Currency() != currency:
+			errs.add("OrderTaxAmount", "currency %q does not match order currency %q", tax.Currency(), currency)
+		case tax.Minor() < 0:
+			errs.add("OrderTaxAmount", "must not be negative")
+		case tax.Minor() > f.OrderAmount.Minor():
+			errs.add("OrderTaxAmount", "must not exceed OrderAmount")
+		}
+	}
+
+	validateCallbackURL(&errs, "SuccessURL", f.SuccessURL)
+	validateCallbackURL(&errs, "ErrorURL", f.ErrorURL)
+	validateCallbackURL(&errs, "CancelURL", f.CancelURL)
+
+	return errs.err()
+}
+
+// Valid reports whether o is a known checkout operation.
+func (o Operation) Valid() bool {
+	return o == OperationPurchase || o == OperationVerify
+}
+
+// Valid reports whether m is a known payment method.
+func (m PaymentMethod) Valid() bool {
+	switch m {
+	case Card, BankTransfer, NapasBankTransfer:
+		return true
+	}
+	return false
+}
+
+// validateInvoiceNumber checks that an invoice number is present, not too
+// long and made of letters, digits, '-', '_' and '.' only.
+func validateInvoiceNumber(errs *ValidationErrors, invoiceNumber string) {
+	if invoiceNumber == "" {
+		errs.add("OrderInvoiceNumber", "must not be empty")
+		return
+	}
+	if len(invoiceNumber) > maxInvoiceNumberLength {
+		errs.add("OrderInvoiceNumber", "must be at most %d characters", maxInvoiceNumberLength)
+	}
+	for _, r := range invoiceNumber {
+		if !isInvoiceNumberRune(r) {
+			errs.add("OrderInvoiceNumber", "contains invalid character %q", r)
+			return
+		}
+	}
+}
+
+func isInvoiceNumberRune(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return true
+	case r == '-', r == '_', r == '.':
+		return true
+	}
+	return false
+}
+
+// validateCallbackURL checks that an optional URL is an absolute http(s) URL.
+func validateCallbackURL(errs *ValidationErrors, field string, rawURL *string) {
+	if rawURL == nil {
+		return
+	}
+	u, err := url.Parse(*rawURL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		errs.add(field, "must be an absolute http(s) URL")
+	}
+}
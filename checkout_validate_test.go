@@ -0,0 +1,123 @@
+package sepay
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func validOnetimePaymentFields() OnetimePaymentFields {
+	return OnetimePaymentFields{
+		PaymentMethod:      BankTransfer,
+		OrderInvoiceNumber: "INV-001",
+		OrderAmount:        VND(50000),
+		OrderDescription:   "Test order",
+		OrderTaxAmount:     AmountPtr(VND(5000)),
+		SuccessURL:         String("https://example.com/success"),
+		ErrorURL:           String("http://localhost:8080/error"),
+	}
+}
+
+func TestOnetimePaymentFields_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(f *OnetimePaymentFields)
+		fields []string
+	}{
+		{"valid", func(f *OnetimePaymentFields) {}, nil},
+		{"empty invoice number", func(f *OnetimePaymentFields) { f.OrderInvoiceNumber = "" }, []string{"OrderInvoiceNumber"}},
+		{"invoice number too long", func(f *OnetimePaymentFields) { f.OrderInvoiceNumber = strings.Repeat("A", 51) }, []string{"OrderInvoiceNumber"}},
+		{"invoice number charset", func(f *OnetimePaymentFields) { f.OrderInvoiceNumber = "INV 001/ä" }, []string{"OrderInvoiceNumber"}},
+		{"zero amount", func(f *OnetimePaymentFields) { f.OrderAmount = VND(0); f.OrderTaxAmount = nil }, []string{"OrderAmount"}},
+		{"negative amount", func(f *OnetimePaymentFields) { f.OrderAmount = VND(-1); f.OrderTaxAmount = nil }, []string{"OrderAmount"}},
+		{"missing currency", func(f *OnetimePaymentFields) { f.OrderAmount = Amount{}; f.OrderTaxAmount = nil }, []string{"OrderAmount", "OrderAmount"}},
+		{"unknown currency", func(f *OnetimePaymentFields) { f.OrderAmount = NewAmount(100, "ABC"); f.OrderTaxAmount = nil }, []string{"OrderAmount"}},
+		{"tax exceeds amount", func(f *OnetimePaymentFields) { f.OrderTaxAmount = AmountPtr(VND(60000)) }, []string{"OrderTaxAmount"}},
+		{"tax currency mismatch", func(f *OnetimePaymentFields) { f.OrderTaxAmount = AmountPtr(NewAmount(100, "USD")) }, []string{"OrderTaxAmount"}},
+		{"relative URL", func(f *OnetimePaymentFields) { f.SuccessURL = String("/success") }, []string{"SuccessURL"}},
+		{"non-http URL", func(f *OnetimePaymentFields) { f.CancelURL = String("javascript:alert(1)") }, []string{"CancelURL"}},
+		{"unknown operation", func(f *OnetimePaymentFields) { f.Operation = "REFUND" }, []string{"Operation"}},
+		{"unknown payment method", func(f *OnetimePaymentFields) { f.PaymentMethod = "CASH" }, []string{"PaymentMethod"}},
+		{"verify requires card", func(f *OnetimePaymentFields) { f.Operation = OperationVerify }, []string{"PaymentMethod"}},
+		{"verify allows zero amount", func(f *OnetimePaymentFields) {
+			f.Operation = OperationVerify
+			f.PaymentMethod = Card
+			f.OrderAmount = VND(0)
+			f.OrderTaxAmount = nil
+		}, nil},
+		{"multiple problems", func(f *OnetimePaymentFields) {
+			f.OrderInvoiceNumber = ""
+			f.ErrorURL = String("not a url")
+		}, []string{"OrderInvoiceNumber", "ErrorURL"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fields := validOnetimePaymentFields()
+			tc.modify(&fields)
+			err := fields.Validate()
+			if tc.fields == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var errs ValidationErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("expected ValidationErrors, got %T: %v", err, err)
+			}
+			var got []string
+			for _, e := range errs {
+				got = append(got, e.Field)
+			}
+			if strings.Join(got, ",") != strings.Join(tc.fields, ",") {
+				t.Errorf("expected fields %v, got %v (%v)", tc.fields, got, err)
+			}
+		})
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	errs := ValidationErrors{
+		{Field: "OrderInvoiceNumber", Message: "must not be empty"},
+		{Field: "SuccessURL", Message: "must be an absolute http(s) URL"},
+	}
+	expected := "sepay: validation error: OrderInvoiceNumber: must not be empty; SuccessURL: must be an absolute http(s) URL"
+	if errs.Error() != expected {
+		t.Errorf("expected %q, got %q", expected, errs.Error())
+	}
+
+	var valErr *ValidationError
+	if !errors.As(error(errs), &valErr) || valErr.Field != "OrderInvoiceNumber" {
+		t.Errorf("expected errors.As to find the first *ValidationError, got %v", valErr)
+	}
+}
+
+func TestCheckoutService_SignOneTimePayment(t *testing.T) {
+	c, _ := NewClient(Config{
+		Env:        Sandbox,
+		MerchantID: "test_merchant",
+		SecretKey:  "test_secret",
+	})
+
+	t.Run("valid fields are signed", func(t *testing.T) {
+		fields := validOnetimePaymentFields()
+		signed, err := c.Checkout.SignOneTimePayment(fields)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if expected := c.Checkout.InitOneTimePaymentFields(fields); signed.Signature != expected.Signature {
+			t.Errorf("expected signature %q, got %q", expected.Signature, signed.Signature)
+		}
+	})
+
+	t.Run("invalid fields are rejected", func(t *testing.T) {
+		signed, err := c.Checkout.SignOneTimePayment(OnetimePaymentFields{})
+		if err == nil {
+			t.Fatal("expected validation error")
+		}
+		if signed != nil {
+			t.Error("expected nil signed fields")
+		}
+	})
+}
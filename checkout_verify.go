@@ -0,0 +1,78 @@
+package sepay
+
+import "context"
+
+// CardVerificationFields holds the fields for a card VERIFY checkout, which
+// authenticates the customer's card without charging it so that the
+// resulting token can be used for later payments.
+type CardVerificationFields struct {
+	OrderInvoiceNumber string
+	// OrderAmount is the verification amount, usually zero or a nominal
+	// value. A zero Amount without a currency defaults to 0 VND.
+	OrderAmount      Amount
+	OrderDescription string
+	// CustomerID identifies the customer the saved card belongs to.
+	CustomerID string
+	SuccessURL *string
+	ErrorURL   *string
+	CancelURL  *string
+	CustomData *string
+}
+
+// onetime returns the fields as a VERIFY one-time payment.
+func (f *CardVerificationFields) onetime() OnetimePaymentFields {
+	amount := f.OrderAmount
+	if amount.Currency() == "" && amount.IsZero() {
+		amount = VND(0)
+	}
+	return OnetimePaymentFields{
+		Operation:          OperationVerify,
+		PaymentMethod:      Card,
+		OrderInvoiceNumber: f.OrderInvoiceNumber,
+		OrderAmount:        amount,
+		OrderDescription:   f.OrderDescription,
+		CustomerID:         String(f.CustomerID),
+		SuccessURL:         f.SuccessURL,
+		ErrorURL:           f.ErrorURL,
+		CancelURL:          f.CancelURL,
+		CustomData:         f.CustomData,
+	}
+}
+
+// Validate checks the fields before signing and returns ValidationErrors
+// listing every problem found, or nil.
+func (f *CardVerificationFields) Validate() error {
+	var errs ValidationErrors
+	onetime := f.onetime()
+	if err := onetime.Validate(); err != nil {
+		errs = append(errs, err.(ValidationErrors)...)
+	}
+	if f.CustomerID == "" {
+		errs.add("CustomerID", "must not be empty")
+	}
+	return errs.err()
+}
+
+// InitCardVerificationFields validates the given card verification fields and
+// returns the complete set of checkout fields for a VERIFY operation with the
+// CARD payment method, signed with HMAC-SHA256. Invalid fields are reported
+// as ValidationErrors.
+//
+// Once the customer completes the checkout, the saved card is available as
+// the Agreement of the order returned by OrderService.RetrieveOrder.
+func (s *CheckoutService) InitCardVerificationFields(fields CardVerificationFields) (*SignedCheckoutFields, error) {
+	return s.InitCardVerificationFieldsContext(context.Background(), fields)
+}
+
+// InitCardVerificationFieldsContext is like InitCardVerificationFields,
+// passing ctx to the Config.SecretProvider and the client's hooks.
+func (s *CheckoutService) InitCardVerificationFieldsContext(ctx context.Context, fields CardVerificationFields) (*SignedCheckoutFields, error) {
+	if err := fields.Validate(); err != nil {
+		return nil, err
+	}
+	signed := s.newSignedFields(fields.onetime())
+	if err := s.sign(ctx, signed); err != nil {
+		return nil, err
+	}
+	return signed, nil
+}
@@ -0,0 +1,102 @@
+package sepay
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheckoutService_InitCardVerificationFields(t *testing.T) {
+	c, _ := NewClient(Config{
+		Env:        Sandbox,
+		MerchantID: "test_merchant",
+		SecretKey:  "test_secret",
+	})
+
+	t.Run("zero amount defaults to VND", func(t *testing.T) {
+		signed, err := c.Checkout.InitCardVerificationFields(CardVerificationFields{
+			OrderInvoiceNumber: "VER-001",
+			OrderDescription:   "Save card",
+			CustomerID:         "CUST-001",
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if signed.Operation != OperationVerify {
+			t.Errorf("expected operation %q, got %q", OperationVerify, signed.Operation)
+		}
+		if signed.PaymentMethod != Card {
+			t.Errorf("expected payment method %q, got %q", Card, signed.PaymentMethod)
+		}
+
+		data := strings.Join([]string{
+			"merchant=test_merchant",
+			"operation=VERIFY",
+			"payment_method=CARD",
+			"order_amount=0",
+			"currency=VND",
+			"order_invoice_number=VER-001",
+			"order_description=Save card",
+			"customer_id=CUST-001",
+		}, ",")
+		mac := hmac.New(sha256.New, []byte("test_secret"))
+		mac.Write([]byte(data))
+		expectedSig := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+		if signed.Signature != expectedSig {
+			t.Errorf("signature mismatch:\n  expected: %s\n  got:      %s", expectedSig, signed.Signature)
+		}
+	})
+
+	t.Run("nominal amount", func(t *testing.T) {
+		signed, err := c.Checkout.InitCardVerificationFields(CardVerificationFields{
+			OrderInvoiceNumber: "VER-002",
+			OrderAmount:        NewAmount(100, "USD"),
+			CustomerID:         "CUST-001",
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if signed.Currency != "USD" || signed.OrderAmount.String() != "1" {
+			t.Errorf("unexpected amount %s %s", signed.OrderAmount, signed.Currency)
+		}
+	})
+
+	t.Run("context", func(t *testing.T) {
+		c, _ := NewClient(Config{Env: Sandbox, MerchantID: "test_merchant", SecretKey: "test_secret"})
+		var got any
+		c.AddHooks(Hooks{
+			CheckoutSigned: func(ctx context.Context, fields *SignedCheckoutFields, err error) {
+				got = ctx.Value(hookCtxKey{})
+			},
+		})
+		ctx := context.WithValue(context.Background(), hookCtxKey{}, "request")
+		_, err := c.Checkout.InitCardVerificationFieldsContext(ctx, CardVerificationFields{
+			OrderInvoiceNumber: "VER-004",
+			CustomerID:         "CUST-001",
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != "request" {
+			t.Errorf("expected the caller's context to reach the hooks, got %v", got)
+		}
+	})
+
+	t.Run("customer ID required", func(t *testing.T) {
+		_, err := c.Checkout.InitCardVerificationFields(CardVerificationFields{
+			OrderInvoiceNumber: "VER-003",
+		})
+		var errs ValidationErrors
+		if !errors.As(err, &errs) {
+			t.Fatalf("expected ValidationErrors, got %T", err)
+		}
+		if len(errs) != 1 || errs[0].Field != "CustomerID" {
+			t.Errorf("expected a single CustomerID error, got %v", errs)
+		}
+	})
+}
@@ -0,0 +1,190 @@
+package sepay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFailureThreshold = 5
+	defaultOpenTimeout      = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned, without a request being sent, while the
+// circuit breaker of an operation is open.
+var ErrCircuitOpen = errors.New("sepay: circuit breaker is open")
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+const (
+	// CircuitClosed lets requests through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects requests with ErrCircuitOpen.
+	CircuitOpen
+	// CircuitHalfOpen lets a single trial request through to decide
+	// whether to close the circuit again.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+// CircuitBreakerSettings configures a CircuitBreaker.
+type CircuitBreakerSettings struct {
+	// FailureThreshold is the number of consecutive failed requests that
+	// opens the circuit of an operation. Defaults to 5.
+	FailureThreshold int
+	// Operations overrides FailureThreshold for individual operations,
+	// keyed like RateLimit.Operations.
+	Operations map[string]int
+	// OpenTimeout is how long a circuit stays open before a trial request
+	// is let through. Defaults to 30 seconds.
+	OpenTimeout time.Duration
+	// OnStateChange, if set, is called whenever the circuit of an
+	// operation changes state, e.g. to alert on outages. It must not
+	// block.
+	OnStateChange func(operation string, from, to CircuitState)
+}
+
+// CircuitBreaker stops sending requests for an operation after repeated
+// failures, so that callers fail fast with ErrCircuitOpen during a SePay
+// outage instead of waiting for their contexts to time out. Each operation
+// has its own circuit. Network errors, timeouts and 5xx responses count as
+// failures; other API errors do not.
+//
+// A CircuitBreaker is safe for concurrent use and may be shared by several
+// clients.
+type CircuitBreaker struct {
+	settings CircuitBreakerSettings
+	now      func() time.Time
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+// circuit is the state of the breaker for one operation.
+type circuit struct {
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	// probing reports whether the half-open trial request is in flight.
+	probing bool
+}
+
+// NewCircuitBreaker returns a CircuitBreaker with the given settings.
+func NewCircuitBreaker(settings CircuitBreakerSettings) *CircuitBreaker {
+	if settings.FailureThreshold <= 0 {
+		settings.FailureThreshold = defaultFailureThreshold
+	}
+	if settings.OpenTimeout <= 0 {
+		settings.OpenTimeout = defaultOpenTimeout
+	}
+	return &CircuitBreaker{
+		settings: settings,
+		now:      time.Now,
+		circuits: make(map[string]*circuit),
+	}
+}
+
+// State returns the state of the circuit of the given operation.
+func (b *CircuitBreaker) State(operation string) CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c := b.circuits[operation]; c != nil {
+		if c.state == CircuitOpen && b.now().Sub(c.openedAt) >= b.settings.OpenTimeout {
+			return CircuitHalfOpen
+		}
+		return c.state
+	}
+	return CircuitClosed
+}
+
+// allow reports whether a request for the operation may be sent, returning
+// ErrCircuitOpen if not.
+func (b *CircuitBreaker) allow(operation string) error {
+	b.mu.Lock()
+	c := b.circuits[operation]
+	if c == nil {
+		c = &circuit{}
+		b.circuits[operation] = c
+	}
+	from := c.state
+	if c.state == CircuitOpen && b.now().Sub(c.openedAt) >= b.settings.OpenTimeout {
+		c.state = CircuitHalfOpen
+	}
+	var err error
+	switch {
+	case c.state == CircuitOpen, c.state == CircuitHalfOpen && c.probing:
+		err = fmt.Errorf("%w (%s)", ErrCircuitOpen, operation)
+	case c.state == CircuitHalfOpen:
+		c.probing = true
+	}
+	to := c.state
+	b.mu.Unlock()
+
+	b.notify(operation, from, to)
+	return err
+}
+
+// record records the outcome of a request allowed by allow.
+func (b *CircuitBreaker) record(operation string, resp *Response, err error) {
+	b.mu.Lock()
+	c := b.circuits[operation]
+	from := c.state
+	halfOpen := c.state == CircuitHalfOpen
+	if halfOpen {
+		c.probing = false
+	}
+	switch {
+	case isCircuitFailure(resp, err):
+		c.failures++
+		threshold := b.settings.FailureThreshold
+		if n, ok := b.settings.Operations[operation]; ok && n > 0 {
+			threshold = n
+		}
+		if halfOpen || c.failures >= threshold {
+			c.state, c.openedAt = CircuitOpen, b.now()
+		}
+	case resp != nil:
+		c.state, c.failures = CircuitClosed, 0
+	}
+	// A request ending without a response or failure, e.g. cancelled by
+	// the caller, leaves the circuit as it was.
+	to := c.state
+	b.mu.Unlock()
+
+	b.notify(operation, from, to)
+}
+
+func (b *CircuitBreaker) notify(operation string, from, to CircuitState) {
+	if from != to && b.settings.OnStateChange != nil {
+		b.settings.OnStateChange(operation, from, to)
+	}
+}
+
+// isCircuitFailure reports whether a request outcome indicates that SePay is
+// unavailable: a 5xx response, or a network error or timeout.
+func isCircuitFailure(resp *Response, err error) bool {
+	if resp != nil {
+		return resp.StatusCode >= 500
+	}
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,160 @@
+package sepay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	status := 503
+	var requests int
+	c, ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(status)
+		w.Write([]byte(`{"data":{"order_invoice_number":"INV-001"}}`))
+	})
+	defer ts.Close()
+
+	var changes []string
+	b := NewCircuitBreaker(CircuitBreakerSettings{
+		FailureThreshold: 3,
+		Operations:       map[string]int{"Order.All": 1},
+		OpenTimeout:      time.Minute,
+		OnStateChange: func(operation string, from, to CircuitState) {
+			changes = append(changes, fmt.Sprintf("%s:%s->%s", operation, from, to))
+		},
+	})
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	b.now = func() time.Time { return now }
+	c.config.CircuitBreaker = b
+	c.config.Retry = NoRetries
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.Order.Retrieve(ctx, "INV-001"); errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("attempt %d: circuit opened too early", i+1)
+		}
+	}
+	if _, err := c.Order.Retrieve(ctx, "INV-001"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected ErrCircuitOpen, got %v", err)
+	}
+	if requests != 3 {
+		t.Errorf("expected the open circuit not to send requests, got %d", requests)
+	}
+	if b.State("Order.Retrieve") != CircuitOpen || b.State("Order.All") != CircuitClosed {
+		t.Error("expected only the Retrieve circuit to be open")
+	}
+
+	// After the timeout a single trial request is let through; its
+	// failure opens the circuit again.
+	now = now.Add(time.Minute)
+	if b.State("Order.Retrieve") != CircuitHalfOpen {
+		t.Errorf("expected half-open, got %s", b.State("Order.Retrieve"))
+	}
+	if _, err := c.Order.Retrieve(ctx, "INV-001"); errors.Is(err, ErrCircuitOpen) {
+		t.Fatal("expected a trial request")
+	}
+	if _, err := c.Order.Retrieve(ctx, "INV-001"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected ErrCircuitOpen after failed trial, got %v", err)
+	}
+
+	// A successful trial closes it.
+	now = now.Add(time.Minute)
+	status = 200
+	if _, err := c.Order.Retrieve(ctx, "INV-001"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if b.State("Order.Retrieve") != CircuitClosed {
+		t.Errorf("expected closed, got %s", b.State("Order.Retrieve"))
+	}
+
+	// Client errors do not count as failures.
+	status = 404
+	for i := 0; i < 5; i++ {
+		c.Order.Retrieve(ctx, "INV-001")
+	}
+	if b.State("Order.Retrieve") != CircuitClosed {
+		t.Errorf("expected 404s to leave the circuit closed, got %s", b.State("Order.Retrieve"))
+	}
+
+	want := []string{
+		"Order.Retrieve:closed->open",
+		"Order.Retrieve:open->half-open",
+		"Order.Retrieve:half-open->open",
+		"Order.Retrieve:open->half-open",
+		"Order.Retrieve:half-open->closed",
+	}
+	if fmt.Sprint(changes) != fmt.Sprint(want) {
+		t.Errorf("expected state changes %v, got %v", want, changes)
+	}
+}
+
+func TestCircuitBreaker_HalfOpenProbe(t *testing.T) {
+	b := NewCircuitBreaker(CircuitBreakerSettings{FailureThreshold: 1, OpenTimeout: time.Second})
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	b.now = func() time.Time { return now }
+
+	b.allow("Order.Retrieve")
+	b.record("Order.Retrieve", nil, fmt.Errorf("sepay: executing request: %w", context.DeadlineExceeded))
+	now = now.Add(time.Second)
+
+	if err := b.allow("Order.Retrieve"); err != nil {
+		t.Fatalf("expected trial request, got %v", err)
+	}
+	if err := b.allow("Order.Retrieve"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected a concurrent request to be rejected, got %v", err)
+	}
+
+	// A trial cancelled by the caller decides nothing.
+	b.record("Order.Retrieve", nil, context.Canceled)
+	if b.State("Order.Retrieve") != CircuitHalfOpen {
+		t.Errorf("expected half-open, got %s", b.State("Order.Retrieve"))
+	}
+	if err := b.allow("Order.Retrieve"); err != nil {
+		t.Errorf("expected another trial request, got %v", err)
+	}
+}
+
+func TestCircuitBreaker_StopsRetries(t *testing.T) {
+	var requests int
+	c, ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(503)
+	})
+	defer ts.Close()
+
+	c.config.CircuitBreaker = NewCircuitBreaker(CircuitBreakerSettings{FailureThreshold: 1})
+	c.config.Retry = &RetryPolicy{MaxAttempts: 3}
+	var sleeps int
+	c.sleep = func(context.Context, time.Duration) error {
+		sleeps++
+		return nil
+	}
+	var result OperationResult
+	c.AddHooks(Hooks{
+		OperationDone: func(ctx context.Context, info RequestInfo, r OperationResult) {
+			result = r
+		},
+	})
+
+	// The attempt that opens the circuit is the last one, and its outcome
+	// is returned instead of ErrCircuitOpen.
+	resp, err := c.Order.Retrieve(context.Background(), "INV-001")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 503 {
+		t.Fatalf("expected the 503 APIError, got %v", err)
+	}
+	if resp == nil || resp.StatusCode != 503 {
+		t.Errorf("expected the 503 response, got %+v", resp)
+	}
+	if requests != 1 || sleeps != 0 {
+		t.Errorf("expected 1 request and no backoff, got %d requests and %d sleeps", requests, sleeps)
+	}
+	if result.Response == nil || result.Response.StatusCode != 503 || result.Attempts != 1 {
+		t.Errorf("expected hooks to see the 503 after 1 attempt, got %+v", result)
+	}
+}
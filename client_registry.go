@@ -0,0 +1,318 @@
+package sepay
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrUnknownMerchant is returned, possibly wrapped, by a CredentialsLoader or
+// ClientRegistry for a merchant ID that does not exist.
+var ErrUnknownMerchant = errors.New("sepay: unknown merchant")
+
+// Defaults for remembering unknown merchants in a ClientRegistry.
+const (
+	defaultNegativeTTL         = time.Minute
+	defaultMaxUnknownMerchants = 1000
+)
+
+// CredentialsLoader loads the configuration of a merchant for a
+// ClientRegistry, e.g. from the platform's seller database.
+type CredentialsLoader interface {
+	// LoadCredentials returns the configuration of the merchant with the
+	// given ID. Config.MerchantID defaults to merchantID if left empty.
+	// Loaders should return an error wrapping ErrUnknownMerchant for
+	// merchants that do not exist, so that the result can be cached.
+	LoadCredentials(ctx context.Context, merchantID string) (Config, error)
+}
+
+// CredentialsLoaderFunc adapts an ordinary function to the CredentialsLoader
+// interface.
+type CredentialsLoaderFunc func(ctx context.Context, merchantID string) (Config, error)
+
+// LoadCredentials calls f(ctx, merchantID).
+func (f CredentialsLoaderFunc) LoadCredentials(ctx context.Context, merchantID string) (Config, error) {
+	return f(ctx, merchantID)
+}
+
+// RegistryOptions configures a ClientRegistry.
+type RegistryOptions struct {
+	// HTTPClient is shared by every client in the registry. Defaults to a
+	// new http.Client.
+	HTTPClient *http.Client
+	// TTL is how long a client is cached before its credentials are loaded
+	// again. Zero caches clients until they are evicted.
+	TTL time.Duration
+	// NegativeTTL is how long a load failing with ErrUnknownMerchant is
+	// cached, so that webhooks forged for the same merchant ID do not
+	// reach the loader. Defaults to one minute; a negative value disables
+	// it. Other failures are never cached.
+	NegativeTTL time.Duration
+	// MaxUnknownMerchants is the number of unknown merchants cached; the
+	// oldest are dropped beyond it. Defaults to 1000.
+	MaxUnknownMerchants int
+	// KnownMerchant, if set, is a cheap check, e.g. against an in-memory
+	// set or the merchant ID format, made before loading. Merchants it
+	// rejects fail with ErrUnknownMerchant without calling the loader.
+	// Webhook notifications name their merchant before they are verified,
+	// so this keeps forged merchant IDs away from the loader.
+	KnownMerchant func(merchantID string) bool
+}
+
+// ClientRegistry lazily builds and caches one Client per merchant for
+// platforms that process payments on behalf of many merchants. All clients
+// share a single http.Client, and therefore its connection pool.
+type ClientRegistry struct {
+	loader        CredentialsLoader
+	httpClient    *http.Client
+	ttl           time.Duration
+	negativeTTL   time.Duration
+	maxUnknown    int
+	knownMerchant func(merchantID string) bool
+	now           func() time.Time
+
+	mu        sync.Mutex
+	entries   map[string]*registryEntry
+	lastSweep time.Time
+	// unknown lists the cached unknown merchants, most recent first.
+	unknown    *list.List
+	unknownIDs map[string]*list.Element
+}
+
+// registryEntry is a cached client or a client being built. ready is closed
+// once client and err are set.
+type registryEntry struct {
+	ready    chan struct{}
+	client   *Client
+	err      error
+	loadedAt time.Time
+}
+
+// unknownMerchant is a cached load that failed with ErrUnknownMerchant.
+type unknownMerchant struct {
+	id       string
+	err      error
+	failedAt time.Time
+}
+
+// NewClientRegistry returns a ClientRegistry that loads merchant
+// configurations from loader. A nil opts uses the defaults.
+func NewClientRegistry(loader CredentialsLoader, opts *RegistryOptions) *ClientRegistry {
+	if opts == nil {
+		opts = &RegistryOptions{}
+	}
+	httpClient := opts.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	negativeTTL := opts.NegativeTTL
+	if negativeTTL == 0 {
+		negativeTTL = defaultNegativeTTL
+	}
+	maxUnknown := opts.MaxUnknownMerchants
+	if maxUnknown <= 0 {
+		maxUnknown = defaultMaxUnknownMerchants
+	}
+	return &ClientRegistry{
+		loader:        loader,
+		httpClient:    httpClient,
+		ttl:           opts.TTL,
+		negativeTTL:   negativeTTL,
+		maxUnknown:    maxUnknown,
+		knownMerchant: opts.KnownMerchant,
+		now:           time.Now,
+		entries:       make(map[string]*registryEntry),
+		unknown:       list.New(),
+		unknownIDs:    make(map[string]*list.Element),
+	}
+}
+
+// Client returns the client of the merchant with the given ID, loading its
+// credentials on first use or once the cached client is older than the TTL.
+// Concurrent callers for the same merchant share a single load. Failed loads
+// are not cached, except for unknown merchants.
+func (r *ClientRegistry) Client(ctx context.Context, merchantID string) (*Client, error) {
+	if merchantID == "" {
+		return nil, &ConfigError{Field: "MerchantID", Message: "must not be empty"}
+	}
+	if r.knownMerchant != nil && !r.knownMerchant(merchantID) {
+		return nil, fmt.Errorf("%w %q", ErrUnknownMerchant, merchantID)
+	}
+
+	r.mu.Lock()
+	r.pruneUnknownLocked()
+	if u, ok := r.unknownIDs[merchantID]; ok {
+		r.mu.Unlock()
+		return nil, u.Value.(*unknownMerchant).err
+	}
+	e, ok := r.entries[merchantID]
+	if ok && r.expired(e) {
+		delete(r.entries, merchantID)
+		ok = false
+	}
+	if !ok {
+		r.sweepLocked()
+		e = &registryEntry{ready: make(chan struct{})}
+		r.entries[merchantID] = e
+		r.mu.Unlock()
+		go r.load(context.WithoutCancel(ctx), merchantID, e)
+	} else {
+		r.mu.Unlock()
+	}
+
+	select {
+	case <-e.ready:
+		return e.client, e.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// load builds the client for e. It runs with a context detached from the
+// caller's cancellation so that a cancelled caller does not fail the load
+// for others waiting on it.
+func (r *ClientRegistry) load(ctx context.Context, merchantID string, e *registryEntry) {
+	defer close(e.ready)
+
+	cfg, err := r.loader.LoadCredentials(ctx, merchantID)
+	if err == nil {
+		if cfg.MerchantID == "" {
+			cfg.MerchantID = merchantID
+		}
+		if cfg.MerchantID != merchantID {
+			err = fmt.Errorf("sepay: credentials for merchant %q have merchant ID %q", merchantID, cfg.MerchantID)
+		}
+	}
+	var c *Client
+	if err == nil {
+		c, err = NewClient(cfg)
+	}
+
+	if err != nil {
+		e.err = err
+		r.mu.Lock()
+		if r.entries[merchantID] == e {
+			delete(r.entries, merchantID)
+			if errors.Is(err, ErrUnknownMerchant) && r.negativeTTL > 0 {
+				r.addUnknownLocked(merchantID, err)
+			}
+		}
+		r.mu.Unlock()
+		return
+	}
+
+	c.SetHTTPClient(r.httpClient)
+	e.client = c
+	r.mu.Lock()
+	e.loadedAt = r.now()
+	r.mu.Unlock()
+}
+
+// Evict removes the cached client of the merchant, e.g. after its
+// credentials changed. The next call to Client loads them again.
+func (r *ClientRegistry) Evict(merchantID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.entries, merchantID)
+	if u, ok := r.unknownIDs[merchantID]; ok {
+		r.unknown.Remove(u)
+		delete(r.unknownIDs, merchantID)
+	}
+}
+
+// expired reports whether a loaded entry is older than the TTL. The caller
+// must hold r.mu.
+func (r *ClientRegistry) expired(e *registryEntry) bool {
+	if r.ttl <= 0 || e.loadedAt.IsZero() {
+		return false
+	}
+	return r.now().Sub(e.loadedAt) > r.ttl
+}
+
+// sweepLocked removes every expired client, at most once per TTL so that a
+// stream of new merchant IDs does not scan the cache each time. The caller
+// must hold r.mu.
+func (r *ClientRegistry) sweepLocked() {
+	now := r.now()
+	if r.ttl <= 0 || now.Sub(r.lastSweep) < r.ttl {
+		return
+	}
+	r.lastSweep = now
+	for id, e := range r.entries {
+		if r.expired(e) {
+			delete(r.entries, id)
+		}
+	}
+}
+
+// addUnknownLocked caches an unknown merchant, dropping the oldest beyond
+// MaxUnknownMerchants. The caller must hold r.mu.
+func (r *ClientRegistry) addUnknownLocked(merchantID string, err error) {
+	if u, ok := r.unknownIDs[merchantID]; ok {
+		r.unknown.Remove(u)
+	}
+	r.unknownIDs[merchantID] = r.unknown.PushFront(&unknownMerchant{id: merchantID, err: err, failedAt: r.now()})
+	if r.unknown.Len() > r.maxUnknown {
+		oldest := r.unknown.Back()
+		r.unknown.Remove(oldest)
+		delete(r.unknownIDs, oldest.Value.(*unknownMerchant).id)
+	}
+}
+
+// pruneUnknownLocked removes the unknown merchants cached for longer than
+// the NegativeTTL, which are at the back of the list. The caller must hold
+// r.mu.
+func (r *ClientRegistry) pruneUnknownLocked() {
+	now := r.now()
+	for e := r.unknown.Back(); e != nil; e = r.unknown.Back() {
+		u := e.Value.(*unknownMerchant)
+		if now.Sub(u.failedAt) <= r.negativeTTL {
+			return
+		}
+		r.unknown.Remove(e)
+		delete(r.unknownIDs, u.id)
+	}
+}
+
+// NewWebhookHandler returns a WebhookHandler that verifies each notification
+// against the secret keys of the merchant named in its merchant field.
+// The field is read before verification, so set RegistryOptions.KnownMerchant
+// to keep forged merchant IDs away from the loader.
+func (r *ClientRegistry) NewWebhookHandler() *WebhookHandler {
+	return newWebhookHandler(func(ctx context.Context, merchant string) ([]VerificationKey, error) {
+		c, err := r.Client(ctx, merchant)
+		if err != nil {
+			return nil, err
+		}
+		return c.config.verificationKeys(ctx)
+	})
+}
+
+// NewReturnHandler returns a ReturnHandler that confirms orders with the
+// client of the merchant named in the request. merchant extracts the merchant
+// ID from the request; if nil, the "merchant" query value is used, so include
+// it in the SuccessURL, ErrorURL and CancelURL of each merchant.
+func (r *ClientRegistry) NewReturnHandler(merchant func(req *http.Request) string) *ReturnHandler {
+	if merchant == nil {
+		merchant = func(req *http.Request) string {
+			return req.URL.Query().Get("merchant")
+		}
+	}
+	return &ReturnHandler{
+		orders: func(req *http.Request) (*OrderService, error) {
+			id := merchant(req)
+			if id == "" {
+				return nil, errors.New("missing merchant")
+			}
+			c, err := r.Client(req.Context(), id)
+			if err != nil {
+				return nil, err
+			}
+			return c.Order, nil
+		},
+	}
+}
@@ -0,0 +1,280 @@
+package sepay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errNoSuchSeller = errors.New("no such seller")
+
+func newTestLoader(loads *int32) CredentialsLoader {
+	return CredentialsLoaderFunc(func(ctx context.Context, merchantID string) (Config, error) {
+		atomic.AddInt32(loads, 1)
+		if strings.HasPrefix(merchantID, "down-") {
+			return Config{}, errNoSuchSeller
+		}
+		if !strings.HasPrefix(merchantID, "seller-") {
+			return Config{}, fmt.Errorf("loading %s: %w", merchantID, ErrUnknownMerchant)
+		}
+		return Config{Env: Sandbox, SecretKey: "secret-" + merchantID}, nil
+	})
+}
+
+func TestClientRegistry_Client(t *testing.T) {
+	var loads int32
+	httpClient := &http.Client{}
+	r := NewClientRegistry(newTestLoader(&loads), &RegistryOptions{HTTPClient: httpClient})
+
+	var wg sync.WaitGroup
+	clients := make([]*Client, 10)
+	for i := range clients {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c, err := r.Client(context.Background(), "seller-1")
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			clients[i] = c
+		}(i)
+	}
+	wg.Wait()
+
+	if loads != 1 {
+		t.Errorf("expected 1 load, got %d", loads)
+	}
+	for _, c := range clients {
+		if c != clients[0] {
+			t.Fatal("expected every caller to get the same client")
+		}
+	}
+	if c := clients[0]; c.config.MerchantID != "seller-1" || c.config.SecretKey != "secret-seller-1" {
+		t.Errorf("unexpected config: %s/%s", c.config.MerchantID, c.config.SecretKey)
+	}
+	if clients[0].httpClient != httpClient {
+		t.Error("expected the shared http.Client")
+	}
+
+	other, err := r.Client(context.Background(), "seller-2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if other == clients[0] || other.httpClient != httpClient {
+		t.Error("expected a distinct client sharing the http.Client")
+	}
+}
+
+func TestClientRegistry_Errors(t *testing.T) {
+	var loads int32
+	r := NewClientRegistry(newTestLoader(&loads), nil)
+
+	for i := 0; i < 2; i++ {
+		if _, err := r.Client(context.Background(), "down-1"); !errors.Is(err, errNoSuchSeller) {
+			t.Errorf("expected errNoSuchSeller, got %v", err)
+		}
+	}
+	if loads != 2 {
+		t.Errorf("expected failed loads not to be cached, got %d loads", loads)
+	}
+
+	var cfgErr *ConfigError
+	if _, err := r.Client(context.Background(), ""); !errors.As(err, &cfgErr) {
+		t.Errorf("expected *ConfigError, got %v", err)
+	}
+
+	mismatched := NewClientRegistry(CredentialsLoaderFunc(func(ctx context.Context, merchantID string) (Config, error) {
+		return Config{Env: Sandbox, MerchantID: "someone-else", SecretKey: "secret"}, nil
+	}), nil)
+	if _, err := mismatched.Client(context.Background(), "seller-1"); err == nil {
+		t.Error("expected error for mismatched merchant ID")
+	}
+}
+
+func TestClientRegistry_UnknownMerchant(t *testing.T) {
+	t.Run("unknown merchants are cached for the NegativeTTL", func(t *testing.T) {
+		var loads int32
+		r := NewClientRegistry(newTestLoader(&loads), &RegistryOptions{NegativeTTL: time.Minute})
+		now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+		r.now = func() time.Time { return now }
+
+		for i := 0; i < 3; i++ {
+			if _, err := r.Client(context.Background(), "nobody"); !errors.Is(err, ErrUnknownMerchant) {
+				t.Errorf("expected ErrUnknownMerchant, got %v", err)
+			}
+		}
+		if loads != 1 {
+			t.Errorf("expected 1 load, got %d", loads)
+		}
+
+		now = now.Add(2 * time.Minute)
+		r.Client(context.Background(), "nobody")
+		if loads != 2 {
+			t.Errorf("expected a new load after the NegativeTTL, got %d loads", loads)
+		}
+	})
+
+	t.Run("the oldest unknown merchants are dropped beyond the cap", func(t *testing.T) {
+		var loads int32
+		r := NewClientRegistry(newTestLoader(&loads), &RegistryOptions{MaxUnknownMerchants: 2})
+		for _, id := range []string{"nobody-1", "nobody-2", "nobody-3"} {
+			r.Client(context.Background(), id)
+		}
+		if len(r.unknownIDs) != 2 || len(r.entries) != 0 {
+			t.Errorf("expected 2 cached unknown merchants and no clients, got %d and %d", len(r.unknownIDs), len(r.entries))
+		}
+		r.Client(context.Background(), "nobody-3")
+		if loads != 3 {
+			t.Errorf("expected nobody-3 to stay cached, got %d loads", loads)
+		}
+		r.Client(context.Background(), "nobody-1")
+		if loads != 4 {
+			t.Errorf("expected nobody-1 to be loaded again, got %d loads", loads)
+		}
+	})
+
+	t.Run("negative NegativeTTL disables caching", func(t *testing.T) {
+		var loads int32
+		r := NewClientRegistry(newTestLoader(&loads), &RegistryOptions{NegativeTTL: -1})
+		r.Client(context.Background(), "nobody")
+		r.Client(context.Background(), "nobody")
+		if loads != 2 {
+			t.Errorf("expected 2 loads, got %d", loads)
+		}
+	})
+
+	t.Run("KnownMerchant rejects without loading", func(t *testing.T) {
+		var loads int32
+		r := NewClientRegistry(newTestLoader(&loads), &RegistryOptions{
+			KnownMerchant: func(id string) bool { return strings.HasPrefix(id, "seller-") },
+		})
+		if _, err := r.Client(context.Background(), "forged-123"); !errors.Is(err, ErrUnknownMerchant) {
+			t.Errorf("expected ErrUnknownMerchant, got %v", err)
+		}
+		if _, err := r.Client(context.Background(), "seller-1"); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if loads != 1 {
+			t.Errorf("expected only the known merchant to be loaded, got %d loads", loads)
+		}
+	})
+}
+
+func TestClientRegistry_Eviction(t *testing.T) {
+	var loads int32
+	r := NewClientRegistry(newTestLoader(&loads), &RegistryOptions{TTL: time.Minute})
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+
+	first, _ := r.Client(context.Background(), "seller-1")
+	now = now.Add(30 * time.Second)
+	if c, _ := r.Client(context.Background(), "seller-1"); c != first {
+		t.Error("expected cached client within the TTL")
+	}
+
+	now = now.Add(time.Minute)
+	second, _ := r.Client(context.Background(), "seller-1")
+	if second == first {
+		t.Error("expected a new client after the TTL")
+	}
+
+	r.Evict("seller-1")
+	if c, _ := r.Client(context.Background(), "seller-1"); c == second {
+		t.Error("expected a new client after Evict")
+	}
+	if loads != 3 {
+		t.Errorf("expected 3 loads, got %d", loads)
+	}
+}
+
+func TestClientRegistry_NewWebhookHandler(t *testing.T) {
+	var loads int32
+	h := NewClientRegistry(newTestLoader(&loads), nil).NewWebhookHandler()
+	var received string
+	h.On(EventOrderPaid, func(ctx context.Context, event *WebhookEvent) error {
+		received = event.Merchant
+		return nil
+	})
+
+	payload := strings.Replace(testOrderPaidPayload, "{", `{"merchant":"seller-1",`, 1)
+	tests := []struct {
+		name    string
+		payload string
+		secret  string
+		status  int
+	}{
+		{"merchant secret", payload, "secret-seller-1", http.StatusOK},
+		{"other merchant secret", payload, "secret-seller-2", http.StatusUnauthorized},
+		{"unknown merchant", strings.Replace(payload, "seller-1", "nobody", 1), "secret-nobody", http.StatusUnauthorized},
+		{"missing merchant", testOrderPaidPayload, "secret-seller-1", http.StatusUnauthorized},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			received = ""
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, newWebhookRequest(tc.payload, tc.secret))
+			if rec.Code != tc.status {
+				t.Errorf("expected status %d, got %d: %s", tc.status, rec.Code, rec.Body.String())
+			}
+			if tc.status == http.StatusOK && received != "seller-1" {
+				t.Errorf("expected event for seller-1, got %q", received)
+			}
+		})
+	}
+}
+
+// rewriteTransport sends every request to the test server instead of SePay.
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (t rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestClientRegistry_NewReturnHandler(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _, _ := r.BasicAuth()
+		invoice := strings.TrimPrefix(r.URL.Path, "/v1/order/detail/")
+		fmt.Fprintf(w, `{"data":{"order_invoice_number":%q,"order_status":"CAPTURED","customer_id":%q}}`, invoice, user)
+	}))
+	defer ts.Close()
+	target, _ := url.Parse(ts.URL)
+
+	var loads int32
+	r := NewClientRegistry(newTestLoader(&loads), &RegistryOptions{
+		HTTPClient: &http.Client{Transport: rewriteTransport{target}},
+	})
+	h := r.NewReturnHandler(nil)
+	h.OnSuccess = func(w http.ResponseWriter, r *http.Request, order *Order) {
+		fmt.Fprintf(w, "%s:%s", order.CustomerID, order.OrderInvoiceNumber)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/return?merchant=seller-2&order_invoice_number=INV-1", nil))
+	if rec.Body.String() != "seller-2:INV-1" {
+		t.Errorf("expected order confirmed as seller-2, got %q", rec.Body.String())
+	}
+
+	for _, target := range []string{
+		"/return?order_invoice_number=INV-1",
+		"/return?merchant=nobody&order_invoice_number=INV-1",
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", target, rec.Code)
+		}
+	}
+}
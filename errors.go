@@ -1,6 +1,38 @@
 package sepay
 
-import "fmt"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Sentinel errors that an *APIError matches with errors.Is.
+var (
+	ErrUnauthorized          = errors.New("sepay: unauthorized")
+	ErrOrderNotFound         = errors.New("sepay: order not found")
+	ErrOrderAlreadyCancelled = errors.New("sepay: order already cancelled")
+	ErrRateLimited           = errors.New("sepay: rate limited")
+)
+
+// errorCodeSentinels maps SePay error codes to the sentinel they match.
+var errorCodeSentinels = map[string]error{
+	"UNAUTHORIZED":            ErrUnauthorized,
+	"UNAUTHENTICATED":         ErrUnauthorized,
+	"ORDER_NOT_FOUND":         ErrOrderNotFound,
+	"ORDER_ALREADY_CANCELLED": ErrOrderAlreadyCancelled,
+	"ORDER_ALREADY_CANCELED":  ErrOrderAlreadyCancelled,
+	"TOO_MANY_REQUESTS":       ErrRateLimited,
+	"RATE_LIMITED":            ErrRateLimited,
+}
+
+// errorStatusSentinels maps HTTP status codes to the sentinel they match.
+var errorStatusSentinels = map[int]error{
+	http.StatusUnauthorized:    ErrUnauthorized,
+	http.StatusNotFound:        ErrOrderNotFound,
+	http.StatusTooManyRequests: ErrRateLimited,
+}
 
 // ConfigError is returned when the client configuration is invalid.
 type ConfigError struct {
@@ -12,12 +44,160 @@ func (e *ConfigError) Error() string {
 	return fmt.Sprintf("sepay: config error: %s: %s", e.Field, e.Message)
 }
 
+// ValidationError is returned when request parameters fail client-side
+// validation, before any request is sent.
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("sepay: validation error: %s: %s", e.Field, e.Message)
+}
+
+// ValidationErrors reports every validation failure found in a set of
+// fields at once. errors.As finds the individual *ValidationError entries.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Field + ": " + err.Message
+	}
+	return fmt.Sprintf("sepay: validation error: %s", strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the individual validation errors.
+func (e ValidationErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// add appends a validation error for the given field.
+func (e *ValidationErrors) add(field, format string, args ...any) {
+	*e = append(*e, &ValidationError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// err returns e as an error, or nil if it is empty.
+func (e ValidationErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
 // APIError is returned when the API returns an HTTP status code >= 400.
+//
+// Code, Message and Errors are decoded from the SePay error envelope when the
+// body contains one. Use errors.Is with the Err* sentinels to branch on the
+// kind of failure.
 type APIError struct {
 	StatusCode int
 	Body       []byte
+
+	// Code is the machine-readable SePay error code, if any.
+	Code string
+	// Message is the human-readable error message, if any.
+	Message string
+	// Errors holds per-field validation messages keyed by field name.
+	Errors map[string][]string
 }
 
 func (e *APIError) Error() string {
-	return fmt.Sprintf("sepay: api error: status %d: %s", e.StatusCode, string(e.Body))
+	if e.Code == "" && e.Message == "" {
+		return fmt.Sprintf("sepay: api error: status %d: %s", e.StatusCode, string(e.Body))
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "sepay: api error: status %d", e.StatusCode)
+	if e.Code != "" {
+		b.WriteString(": " + e.Code)
+	}
+	if e.Message != "" {
+		b.WriteString(": " + e.Message)
+	}
+	return b.String()
+}
+
+// Is reports whether the error matches one of the Err* sentinels, based on the
+// SePay error code or, failing that, the HTTP status code.
+func (e *APIError) Is(target error) bool {
+	if sentinel, ok := errorCodeSentinels[strings.ToUpper(e.Code)]; ok {
+		return sentinel == target
+	}
+	return errorStatusSentinels[e.StatusCode] == target
+}
+
+// newAPIError builds an APIError for the given response, decoding the SePay
+// error envelope when present. Bodies that are not JSON are kept raw.
+func newAPIError(statusCode int, body []byte) *APIError {
+	e := &APIError{StatusCode: statusCode, Body: body}
+
+	var envelope struct {
+		errorDetails
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return e
+	}
+	details := envelope.errorDetails
+
+	// The error key is either a message string or a nested details object.
+	if len(envelope.Error) > 0 {
+		var msg string
+		var nested errorDetails
+		if err := json.Unmarshal(envelope.Error, &msg); err == nil {
+			if details.Message == "" {
+				details.Message = msg
+			}
+		} else if err := json.Unmarshal(envelope.Error, &nested); err == nil {
+			if nested.Code != "" {
+				details.Code = nested.Code
+			}
+			if nested.Message != "" {
+				details.Message = nested.Message
+			}
+			if nested.Errors != nil {
+				details.Errors = nested.Errors
+			}
+		}
+	}
+
+	e.Code = string(details.Code)
+	e.Message = details.Message
+	e.Errors = details.fieldErrors()
+	return e
+}
+
+// errorDetails is the shape of a SePay error payload.
+type errorDetails struct {
+	Code    flexString                 `json:"code"`
+	Message string                     `json:"message"`
+	Errors  map[string]json.RawMessage `json:"errors"`
+}
+
+// fieldErrors normalizes validation errors, which SePay encodes either as a
+// single message or a list of messages per field.
+func (d errorDetails) fieldErrors() map[string][]string {
+	if len(d.Errors) == 0 {
+		return nil
+	}
+	m := make(map[string][]string, len(d.Errors))
+	for field, raw := range d.Errors {
+		var msgs []string
+		if err := json.Unmarshal(raw, &msgs); err == nil {
+			m[field] = msgs
+			continue
+		}
+		var msg string
+		if err := json.Unmarshal(raw, &msg); err == nil {
+			m[field] = []string{msg}
+		}
+	}
+	return m
 }
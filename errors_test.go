@@ -0,0 +1,100 @@
+package sepay
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNewAPIError(t *testing.T) {
+	t.Run("top-level envelope with field errors", func(t *testing.T) {
+		err := newAPIError(422, []byte(`{
+			"code": "VALIDATION_ERROR",
+			"message": "The given data was invalid.",
+			"errors": {
+				"order_invoice_number": ["is required", "is too long"],
+				"per_page": "must be positive"
+			}
+		}`))
+		if err.Code != "VALIDATION_ERROR" {
+			t.Errorf("expected code %q, got %q", "VALIDATION_ERROR", err.Code)
+		}
+		if err.Message != "The given data was invalid." {
+			t.Errorf("unexpected message %q", err.Message)
+		}
+		if got := err.Errors["order_invoice_number"]; len(got) != 2 || got[1] != "is too long" {
+			t.Errorf("unexpected field errors %v", got)
+		}
+		if got := err.Errors["per_page"]; len(got) != 1 || got[0] != "must be positive" {
+			t.Errorf("unexpected field errors %v", got)
+		}
+		expected := "sepay: api error: status 422: VALIDATION_ERROR: The given data was invalid."
+		if err.Error() != expected {
+			t.Errorf("expected %q, got %q", expected, err.Error())
+		}
+	})
+
+	t.Run("nested error object", func(t *testing.T) {
+		err := newAPIError(400, []byte(`{"error":{"code":"ORDER_ALREADY_CANCELLED","message":"Order already cancelled"}}`))
+		if err.Code != "ORDER_ALREADY_CANCELLED" {
+			t.Errorf("expected code %q, got %q", "ORDER_ALREADY_CANCELLED", err.Code)
+		}
+		if err.Message != "Order already cancelled" {
+			t.Errorf("unexpected message %q", err.Message)
+		}
+	})
+
+	t.Run("error string", func(t *testing.T) {
+		err := newAPIError(401, []byte(`{"error":"unauthorized"}`))
+		if err.Message != "unauthorized" {
+			t.Errorf("expected message %q, got %q", "unauthorized", err.Message)
+		}
+	})
+
+	t.Run("non-JSON body", func(t *testing.T) {
+		err := newAPIError(502, []byte(`<html>Bad Gateway</html>`))
+		if err.Code != "" || err.Message != "" {
+			t.Errorf("expected no decoded details, got %q %q", err.Code, err.Message)
+		}
+		expected := "sepay: api error: status 502: <html>Bad Gateway</html>"
+		if err.Error() != expected {
+			t.Errorf("expected %q, got %q", expected, err.Error())
+		}
+	})
+}
+
+func TestAPIError_Is(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{"401 status", 401, `{}`, ErrUnauthorized},
+		{"404 status", 404, `{"message":"Not found"}`, ErrOrderNotFound},
+		{"429 status", 429, ``, ErrRateLimited},
+		{"already cancelled code", 400, `{"code":"ORDER_ALREADY_CANCELLED"}`, ErrOrderAlreadyCancelled},
+		{"lowercase code", 400, `{"code":"order_not_found"}`, ErrOrderNotFound},
+	}
+	sentinels := []error{ErrUnauthorized, ErrOrderNotFound, ErrOrderAlreadyCancelled, ErrRateLimited}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var err error = newAPIError(tc.status, []byte(tc.body))
+			for _, sentinel := range sentinels {
+				if got := errors.Is(err, sentinel); got != (sentinel == tc.want) {
+					t.Errorf("errors.Is(err, %v) = %v", sentinel, got)
+				}
+			}
+		})
+	}
+
+	t.Run("code takes precedence over status", func(t *testing.T) {
+		err := newAPIError(404, []byte(`{"code":"ORDER_ALREADY_CANCELLED"}`))
+		if errors.Is(err, ErrOrderNotFound) {
+			t.Error("expected code to take precedence over status")
+		}
+		if !errors.Is(err, ErrOrderAlreadyCancelled) {
+			t.Error("expected ErrOrderAlreadyCancelled")
+		}
+	})
+}
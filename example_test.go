@@ -32,7 +32,7 @@ func ExampleOrderService_All() {
 
 	resp, err := client.Order.All(context.Background(), &sepay.OrderQueryParams{
 		PerPage:     sepay.Int(10),
-		OrderStatus: sepay.String("COMPLETED"),
+		OrderStatus: sepay.OrderStatusCaptured,
 	})
 	if err != nil {
 		log.Fatal(err)
@@ -41,7 +41,7 @@ func ExampleOrderService_All() {
 	fmt.Println(resp.StatusCode)
 }
 
-func ExampleCheckoutService_InitOneTimePaymentFields() {
+func ExampleCheckoutService_SignOneTimePayment() {
 	client, err := sepay.NewClient(sepay.Config{
 		Env:        sepay.Sandbox,
 		MerchantID: "your_merchant_id",
@@ -52,16 +52,18 @@ func ExampleCheckoutService_InitOneTimePaymentFields() {
 	}
 
 	checkoutURL := client.Checkout.InitCheckoutURL()
-	signed := client.Checkout.InitOneTimePaymentFields(sepay.OnetimePaymentFields{
+	signed, err := client.Checkout.SignOneTimePayment(sepay.OnetimePaymentFields{
 		OrderInvoiceNumber: "INV-001",
-		OrderAmount:        50000,
-		Currency:           "VND",
+		OrderAmount:        sepay.VND(50000),
 		OrderDescription:   "Payment for Order INV-001",
 		PaymentMethod:      sepay.BankTransfer,
 		SuccessURL:         sepay.String("https://example.com/success"),
 		ErrorURL:           sepay.String("https://example.com/error"),
 		CancelURL:          sepay.String("https://example.com/cancel"),
 	})
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	fmt.Println("Checkout URL:", checkoutURL)
 	fmt.Println("Signature:", signed.Signature)
@@ -14,3 +14,8 @@ func Int(i int) *int {
 func Float64(f float64) *float64 {
 	return &f
 }
+
+// AmountPtr returns a pointer to the given Amount value.
+func AmountPtr(a Amount) *Amount {
+	return &a
+}
@@ -0,0 +1,169 @@
+package sepay
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Hooks are functions the SDK calls at points of interest, for
+// instrumentation such as tracing and metrics. Any of them may be nil. Hooks
+// must not block.
+//
+// The sepayotel and sepayprom packages provide OpenTelemetry and Prometheus
+// instrumentation built on Hooks.
+type Hooks struct {
+	// OperationStart is called when an API operation starts, before its
+	// first attempt. The returned context, e.g. carrying a span, is used
+	// for the operation's requests and passed to OperationDone. The
+	// Attempt of info is zero.
+	OperationStart func(ctx context.Context, info RequestInfo) context.Context
+	// OperationDone is called when an API operation ends.
+	OperationDone func(ctx context.Context, info RequestInfo, result OperationResult)
+	// Retry is called when a failed attempt is about to be retried after
+	// delay. info describes the failed attempt.
+	Retry func(ctx context.Context, info RequestInfo, delay time.Duration, err error)
+	// RateLimitWait is called after a request waited delay for the
+	// client's RateLimiter and was allowed. Waits that failed, e.g.
+	// because they would outlast the context deadline, are not reported.
+	RateLimitWait func(ctx context.Context, info RequestInfo, delay time.Duration)
+	// CheckoutSigned is called when checkout fields have been signed, or
+	// failed to be signed with err.
+	CheckoutSigned func(ctx context.Context, fields *SignedCheckoutFields, err error)
+	// WebhookStart is called when a WebhookHandler receives a request. The
+	// returned context is used for handling it and passed to WebhookDone.
+	WebhookStart func(ctx context.Context, r *http.Request) context.Context
+	// WebhookDone is called when a WebhookHandler has responded.
+	WebhookDone func(ctx context.Context, result WebhookResult)
+}
+
+// OperationResult is the outcome of an API operation.
+type OperationResult struct {
+	// Response is the response of the last attempt, or nil if none was
+	// received.
+	Response *Response
+	// Err is the error returned by the operation, if any.
+	Err error
+	// OrderStatus is the status of the order the operation returned, for
+	// Retrieve and RetrieveOrder.
+	OrderStatus OrderStatus
+	// Attempts is the number of attempts made.
+	Attempts int
+	// Duration is the time the operation took, including retries.
+	Duration time.Duration
+}
+
+// WebhookOutcome is how a WebhookHandler dealt with a request.
+type WebhookOutcome string
+
+const (
+	WebhookAccepted         WebhookOutcome = "accepted"
+	WebhookMethodNotAllowed WebhookOutcome = "method_not_allowed"
+	WebhookInvalidRequest   WebhookOutcome = "invalid_request"
+	WebhookUnauthorized     WebhookOutcome = "unauthorized"
+	WebhookInvalidPayload   WebhookOutcome = "invalid_payload"
+	WebhookStale            WebhookOutcome = "stale"
+	WebhookStoreError       WebhookOutcome = "store_error"
+	WebhookHandlerError     WebhookOutcome = "handler_error"
+)
+
+// WebhookResult is the outcome of a request to a WebhookHandler.
+type WebhookResult struct {
+	Outcome WebhookOutcome
+	// StatusCode is the HTTP status the handler responded with.
+	StatusCode int
+	// Event is the decoded notification, or nil if it was not decoded.
+	Event *WebhookEvent
+	// Err is the error returned by the registered handler function, for
+	// WebhookHandlerError.
+	Err error
+	// Duration is the time the request took to handle.
+	Duration time.Duration
+}
+
+// AddHooks registers hooks with the client. Hooks registered first are
+// called first. AddHooks must be called before the client is used
+// concurrently.
+func (c *Client) AddHooks(hooks Hooks) {
+	c.hooks = append(c.hooks, hooks)
+}
+
+// AddHooks registers hooks with the handler. Handlers created with
+// Client.NewWebhookHandler start with the client's hooks. AddHooks must be
+// called before the handler serves requests.
+func (h *WebhookHandler) AddHooks(hooks Hooks) {
+	h.hooks = append(h.hooks, hooks)
+}
+
+func (c *Client) operationStart(ctx context.Context, info RequestInfo) context.Context {
+	for _, h := range c.hooks {
+		if h.OperationStart != nil {
+			ctx = h.OperationStart(ctx, info)
+		}
+	}
+	return ctx
+}
+
+func (c *Client) operationDone(ctx context.Context, info RequestInfo, result OperationResult) {
+	for _, h := range c.hooks {
+		if h.OperationDone != nil {
+			h.OperationDone(ctx, info, result)
+		}
+	}
+}
+
+func (c *Client) retrying(ctx context.Context, info RequestInfo, delay time.Duration, err error) {
+	for _, h := range c.hooks {
+		if h.Retry != nil {
+			h.Retry(ctx, info, delay, err)
+		}
+	}
+}
+
+func (c *Client) rateLimitWaited(ctx context.Context, info RequestInfo, delay time.Duration) {
+	for _, h := range c.hooks {
+		if h.RateLimitWait != nil {
+			h.RateLimitWait(ctx, info, delay)
+		}
+	}
+}
+
+func (c *Client) checkoutSigned(ctx context.Context, fields *SignedCheckoutFields, err error) {
+	for _, h := range c.hooks {
+		if h.CheckoutSigned != nil {
+			h.CheckoutSigned(ctx, fields, err)
+		}
+	}
+}
+
+func (h *WebhookHandler) webhookStart(ctx context.Context, r *http.Request) context.Context {
+	for _, hooks := range h.hooks {
+		if hooks.WebhookStart != nil {
+			ctx = hooks.WebhookStart(ctx, r)
+		}
+	}
+	return ctx
+}
+
+func (h *WebhookHandler) webhookDone(ctx context.Context, result WebhookResult) {
+	for _, hooks := range h.hooks {
+		if hooks.WebhookDone != nil {
+			hooks.WebhookDone(ctx, result)
+		}
+	}
+}
+
+// peekOrderStatus returns the order status in a successful order detail
+// response, for OperationResult.
+func peekOrderStatus(resp *Response) OrderStatus {
+	var envelope struct {
+		Data struct {
+			OrderStatus OrderStatus `json:"order_status"`
+		} `json:"data"`
+	}
+	if resp == nil || json.Unmarshal(resp.Body, &envelope) != nil {
+		return ""
+	}
+	return envelope.Data.OrderStatus
+}
@@ -0,0 +1,183 @@
+package sepay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type hookCtxKey struct{}
+
+func TestClient_AddHooks(t *testing.T) {
+	var requests int
+	c, ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(503)
+			return
+		}
+		w.Write([]byte(`{"data":{"order_invoice_number":"INV-001","order_status":"CAPTURED"}}`))
+	})
+	defer ts.Close()
+	c.config.Retry = &RetryPolicy{MaxAttempts: 2}
+	c.sleep = func(context.Context, time.Duration) error { return nil }
+
+	var events []string
+	var result OperationResult
+	c.AddHooks(Hooks{
+		OperationStart: func(ctx context.Context, info RequestInfo) context.Context {
+			events = append(events, "start "+info.Operation()+" "+info.InvoiceNumber)
+			return context.WithValue(ctx, hookCtxKey{}, "span")
+		},
+		OperationDone: func(ctx context.Context, info RequestInfo, res OperationResult) {
+			events = append(events, fmt.Sprintf("done %s %v", info.Operation(), ctx.Value(hookCtxKey{})))
+			result = res
+		},
+		Retry: func(ctx context.Context, info RequestInfo, delay time.Duration, err error) {
+			events = append(events, fmt.Sprintf("retry %d %v", info.Attempt, ctx.Value(hookCtxKey{})))
+		},
+	})
+	c.Use(func(next RequestHandler) RequestHandler {
+		return func(req *http.Request, info RequestInfo) (*http.Response, error) {
+			events = append(events, fmt.Sprintf("attempt %d %v", info.Attempt, req.Context().Value(hookCtxKey{})))
+			return next(req, info)
+		}
+	})
+
+	order, _, err := c.Order.RetrieveOrder(context.Background(), "INV-001")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		"start Order.Retrieve INV-001",
+		"attempt 1 span",
+		"retry 1 span",
+		"attempt 2 span",
+		"done Order.Retrieve span",
+	}
+	if fmt.Sprint(events) != fmt.Sprint(want) {
+		t.Errorf("expected events %v, got %v", want, events)
+	}
+	if result.Attempts != 2 || result.OrderStatus != OrderStatusCaptured || result.Err != nil || result.Response.StatusCode != 200 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if order.OrderStatus != OrderStatusCaptured {
+		t.Errorf("expected order status %q, got %q", OrderStatusCaptured, order.OrderStatus)
+	}
+}
+
+func TestClient_AddHooks_RateLimitAndCheckout(t *testing.T) {
+	c, ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":[]}`))
+	})
+	defer ts.Close()
+	l, _, _ := newTestRateLimiter(RateLimit{Limit: Limit{Rate: 1}})
+	c.config.RateLimiter = l
+
+	var waits []time.Duration
+	var signed []*SignedCheckoutFields
+	c.AddHooks(Hooks{
+		RateLimitWait: func(ctx context.Context, info RequestInfo, delay time.Duration) {
+			waits = append(waits, delay)
+		},
+		CheckoutSigned: func(ctx context.Context, fields *SignedCheckoutFields, err error) {
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			signed = append(signed, fields)
+		},
+	})
+
+	c.Order.All(context.Background(), nil)
+	c.Order.All(context.Background(), nil)
+	if len(waits) != 1 || waits[0] != time.Second {
+		t.Errorf("expected one 1s wait, got %v", waits)
+	}
+
+	// A wait that would outlast the deadline fails without waiting.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := c.Order.All(ctx, nil); err == nil {
+		t.Error("expected the rate limit wait to fail")
+	}
+	if len(waits) != 1 {
+		t.Errorf("expected failed waits not to be reported, got %v", waits)
+	}
+
+	fields, err := c.Checkout.SignOneTimePaymentContext(context.Background(), OnetimePaymentFields{
+		PaymentMethod:      BankTransfer,
+		OrderInvoiceNumber: "INV-001",
+		OrderAmount:        VND(100000),
+		OrderDescription:   "Test payment",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(signed) != 1 || signed[0] != fields {
+		t.Errorf("expected the signed fields to be reported, got %v", signed)
+	}
+}
+
+func TestWebhookHandler_AddHooks(t *testing.T) {
+	c, err := NewClient(Config{Env: Sandbox, MerchantID: "merchant123", SecretKey: "secret456"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var results []WebhookResult
+	c.AddHooks(Hooks{
+		WebhookStart: func(ctx context.Context, r *http.Request) context.Context {
+			return context.WithValue(ctx, hookCtxKey{}, "span")
+		},
+		WebhookDone: func(ctx context.Context, result WebhookResult) {
+			if ctx.Value(hookCtxKey{}) != "span" {
+				t.Error("expected the context from WebhookStart")
+			}
+			results = append(results, result)
+		},
+	})
+	h := c.NewWebhookHandler()
+	errFailed := errors.New("failed")
+	failing := true
+	h.On(EventOrderPaid, func(ctx context.Context, event *WebhookEvent) error {
+		if ctx.Value(hookCtxKey{}) != "span" {
+			t.Error("expected the context from WebhookStart")
+		}
+		if failing {
+			return errFailed
+		}
+		return nil
+	})
+
+	h.ServeHTTP(httptest.NewRecorder(), newWebhookRequest(testOrderPaidPayload, "wrong"))
+	h.ServeHTTP(httptest.NewRecorder(), newWebhookRequest(`{`, "secret456"))
+	h.ServeHTTP(httptest.NewRecorder(), newWebhookRequest(testOrderPaidPayload, "secret456"))
+	failing = false
+	h.ServeHTTP(httptest.NewRecorder(), newWebhookRequest(testOrderPaidPayload, "secret456"))
+
+	want := []struct {
+		outcome WebhookOutcome
+		status  int
+		event   bool
+	}{
+		{WebhookUnauthorized, 401, false},
+		{WebhookInvalidPayload, 400, false},
+		{WebhookHandlerError, 500, true},
+		{WebhookAccepted, 200, true},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("expected %d results, got %d", len(want), len(results))
+	}
+	for i, w := range want {
+		r := results[i]
+		if r.Outcome != w.outcome || r.StatusCode != w.status || (r.Event != nil) != w.event {
+			t.Errorf("result %d: expected %v, got %+v", i, w, r)
+		}
+	}
+	if !errors.Is(results[2].Err, errFailed) {
+		t.Errorf("expected handler error, got %v", results[2].Err)
+	}
+}
@@ -0,0 +1,191 @@
+package sepay
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// IdempotencyKeyHeader is the request header carrying the idempotency key of
+// a state-changing request. net/http may re-send a request carrying it once,
+// when a reused connection fails before the request is answered.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+const (
+	// mutationRetention is how long the outcome of a state-changing request
+	// is remembered.
+	mutationRetention = 24 * time.Hour
+	// mutationPruneInterval is how often expired outcomes are removed.
+	mutationPruneInterval = time.Hour
+)
+
+// WithIdempotencyKey sends a state-changing request with the given
+// idempotency key instead of one generated by the client. Use a key derived
+// from your own records, e.g. "cancel-DH0001", to deduplicate requests made
+// from several processes.
+func WithIdempotencyKey(key string) RequestOption {
+	return func(r *apiRequest) {
+		r.idempotencyKey = key
+	}
+}
+
+// newIdempotencyKey returns a random idempotency key.
+func newIdempotencyKey() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// mutation is the state of a state-changing request for one invoice.
+type mutation struct {
+	// key is the idempotency key sent with every attempt.
+	key string
+	// running is non-nil while a call is in flight, and closed when it ends.
+	running chan struct{}
+	// unknown reports whether an earlier call failed without a definite
+	// outcome, i.e. the request may have been applied.
+	unknown bool
+	// resp is the response of the call that succeeded, once done.
+	done      bool
+	resp      *Response
+	updatedAt time.Time
+}
+
+// mutationLog records the state-changing requests made by a client, so that
+// repeated calls for the same invoice share one idempotency key and, once
+// one succeeds, return its outcome without another request.
+type mutationLog struct {
+	now func() time.Time
+
+	mu        sync.Mutex
+	entries   map[string]*mutation
+	lastPrune time.Time
+}
+
+func newMutationLog() *mutationLog {
+	return &mutationLog{now: time.Now, entries: make(map[string]*mutation)}
+}
+
+// begin claims the mutation with the given ID for a new call. If the
+// mutation is already done, its response is returned. If another call is in
+// flight, running is the channel to wait on before trying again. Otherwise
+// the caller owns m until it calls finish; key, if set, replaces the
+// mutation's idempotency key, and unknown reports whether an earlier call
+// ended without a definite outcome.
+func (l *mutationLog) begin(id, key string) (m *mutation, resp *Response, done bool, running chan struct{}, unknown bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastPrune) >= mutationPruneInterval {
+		l.lastPrune = now
+		for k, e := range l.entries {
+			if l.expired(e, now) {
+				delete(l.entries, k)
+			}
+		}
+	}
+
+	m = l.entries[id]
+	if m == nil || l.expired(m, now) {
+		m = &mutation{key: newIdempotencyKey()}
+		l.entries[id] = m
+	}
+	switch {
+	case m.done:
+		return m, m.resp, true, nil, false
+	case m.running != nil:
+		return m, nil, false, m.running, false
+	}
+	m.running = make(chan struct{})
+	if key != "" {
+		m.key = key
+	}
+	return m, nil, false, nil, m.unknown
+}
+
+// expired reports whether m is idle and older than the retention period. The
+// caller must hold l.mu.
+func (l *mutationLog) expired(m *mutation, now time.Time) bool {
+	return m.running == nil && now.Sub(m.updatedAt) > mutationRetention
+}
+
+// finish records the outcome of the call that owns m. uncertain reports
+// whether the call may have been applied despite failing.
+func (l *mutationLog) finish(m *mutation, resp *Response, err error, uncertain bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch {
+	case err == nil:
+		m.done, m.resp = true, resp
+	case uncertain:
+		m.unknown = true
+	}
+	m.updatedAt = l.now()
+	close(m.running)
+	m.running = nil
+}
+
+// mayHaveApplied reports whether an attempt that got resp and err may have
+// been applied by SePay without a definite outcome: the transport failed
+// after trying to send it, or SePay responded with a server error. Requests
+// that were never sent, e.g. rejected by the circuit breaker or the rate
+// limiter, have a definite outcome.
+func mayHaveApplied(resp *Response, err error) bool {
+	if resp != nil {
+		return resp.StatusCode >= 500
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// doMutation sends the state-changing request r for its invoice with an
+// idempotency key. Concurrent and repeated calls for the same endpoint and
+// invoice are deduplicated: they wait for a call in flight, and once a call
+// has succeeded they return its response. If an earlier call or attempt
+// ended without a definite outcome and the request then fails with
+// alreadyDone, the earlier one is taken to have succeeded: a synthesized
+// 200 OK response with an empty JSON object body is returned instead.
+func (a *apiResource) doMutation(ctx context.Context, r *apiRequest, body any, alreadyDone error) (*Response, error) {
+	log := a.client.mutations
+	id := r.endpoint + ":" + r.invoiceNumber
+
+	var m *mutation
+	var unknown bool
+	for {
+		var resp *Response
+		var done bool
+		var running chan struct{}
+		m, resp, done, running, unknown = log.begin(id, r.idempotencyKey)
+		if done {
+			return resp, nil
+		}
+		if running == nil {
+			break
+		}
+		select {
+		case <-running:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	// Only the owner of m reads or writes its key until finish.
+	r.idempotencyKey = m.key
+
+	resp, err := a.doRequestJSON(ctx, r, body)
+	if err != nil && (unknown || r.uncertain) && alreadyDone != nil && errors.Is(err, alreadyDone) {
+		header := http.Header{}
+		if resp != nil {
+			header = resp.Header.Clone()
+		}
+		resp = &Response{StatusCode: http.StatusOK, Header: header, Body: []byte("{}")}
+		err = nil
+	}
+	log.finish(m, resp, err, r.uncertain)
+	return resp, err
+}
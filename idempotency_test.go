@@ -0,0 +1,210 @@
+package sepay
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// mutationServer answers POST requests with the given statuses in turn and
+// records the idempotency keys it receives.
+func newMutationServer(t *testing.T, statuses ...int) (*Client, *[]string, func()) {
+	t.Helper()
+	var mu sync.Mutex
+	var keys []string
+	c, ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys = append(keys, r.Header.Get(IdempotencyKeyHeader))
+		status := statuses[len(keys)-1]
+		mu.Unlock()
+		w.WriteHeader(status)
+		switch status {
+		case 200:
+			w.Write([]byte(`{"message":"cancelled"}`))
+		case 400:
+			w.Write([]byte(`{"code":"ORDER_ALREADY_CANCELLED","message":"Order already cancelled"}`))
+		}
+	})
+	return c, &keys, ts.Close
+}
+
+func TestOrderService_Cancel_Idempotency(t *testing.T) {
+	t.Run("repeated call returns first outcome", func(t *testing.T) {
+		c, keys, closeFn := newMutationServer(t, 200, 400)
+		defer closeFn()
+
+		first, err := c.Order.Cancel(context.Background(), "INV-001")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		second, err := c.Order.Cancel(context.Background(), "INV-001")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if second != first {
+			t.Error("expected the first response")
+		}
+		if len(*keys) != 1 || len((*keys)[0]) != 32 {
+			t.Errorf("expected 1 request with a generated key, got %q", *keys)
+		}
+	})
+
+	t.Run("retry after unknown outcome", func(t *testing.T) {
+		c, keys, closeFn := newMutationServer(t, 503, 400)
+		defer closeFn()
+
+		if _, err := c.Order.Cancel(context.Background(), "INV-001"); err == nil {
+			t.Fatal("expected error")
+		}
+		resp, err := c.Order.Cancel(context.Background(), "INV-001")
+		if err != nil {
+			t.Fatalf("expected already cancelled order to count as success, got %v", err)
+		}
+		if resp.StatusCode != 200 {
+			t.Errorf("expected a successful response, got status %d", resp.StatusCode)
+		}
+		if len(*keys) != 2 || (*keys)[0] != (*keys)[1] {
+			t.Errorf("expected the idempotency key to be reused, got %q", *keys)
+		}
+		if again, err := c.Order.Cancel(context.Background(), "INV-001"); err != nil || again != resp {
+			t.Errorf("expected the recorded success to be returned, got %v, %v", again, err)
+		}
+	})
+
+	t.Run("retry-safe call answered already cancelled", func(t *testing.T) {
+		c, _, closeFn := newMutationServer(t, 503, 400)
+		defer closeFn()
+		c.config.Retry = &RetryPolicy{MaxAttempts: 2}
+		c.sleep = func(context.Context, time.Duration) error { return nil }
+
+		resp, err := c.Order.Cancel(context.Background(), "INV-001", RetrySafe())
+		if err != nil || resp.StatusCode != 200 {
+			t.Errorf("expected success after an unclear first attempt, got %v, %v", resp, err)
+		}
+	})
+
+	unsent := []struct {
+		name  string
+		setup func(c *Client) (undo func())
+	}{
+		{"circuit open", func(c *Client) func() {
+			b := NewCircuitBreaker(CircuitBreakerSettings{FailureThreshold: 1})
+			b.allow("Order.Cancel")
+			b.record("Order.Cancel", &Response{StatusCode: 503}, errors.New("unavailable"))
+			c.config.CircuitBreaker = b
+			return func() { c.config.CircuitBreaker = nil }
+		}},
+		{"secret unavailable", func(c *Client) func() {
+			c.config.SecretProvider = &countingSecret{err: errors.New("vault unavailable")}
+			return func() { c.config.SecretProvider = StaticSecret("secret456") }
+		}},
+	}
+	for _, tc := range unsent {
+		t.Run("unsent request is not an unknown outcome: "+tc.name, func(t *testing.T) {
+			c, keys, closeFn := newMutationServer(t, 400)
+			defer closeFn()
+
+			undo := tc.setup(c)
+			if _, err := c.Order.Cancel(context.Background(), "INV-001"); err == nil {
+				t.Fatal("expected error")
+			}
+			undo()
+			resp, err := c.Order.Cancel(context.Background(), "INV-001")
+			if !errors.Is(err, ErrOrderAlreadyCancelled) {
+				t.Errorf("expected ErrOrderAlreadyCancelled, got %v", err)
+			}
+			if resp == nil || resp.StatusCode != 400 {
+				t.Errorf("expected the error response, got %v", resp)
+			}
+			if len(*keys) != 1 {
+				t.Errorf("expected only the second call to be sent, got %d requests", len(*keys))
+			}
+		})
+	}
+
+	t.Run("already cancelled without earlier attempt", func(t *testing.T) {
+		c, _, closeFn := newMutationServer(t, 404, 400)
+		defer closeFn()
+
+		c.Order.Cancel(context.Background(), "INV-001")
+		if _, err := c.Order.Cancel(context.Background(), "INV-001"); !errors.Is(err, ErrOrderAlreadyCancelled) {
+			t.Errorf("expected ErrOrderAlreadyCancelled, got %v", err)
+		}
+	})
+
+	t.Run("caller-supplied key", func(t *testing.T) {
+		c, keys, closeFn := newMutationServer(t, 200, 200)
+		defer closeFn()
+
+		c.Order.Cancel(context.Background(), "INV-001", WithIdempotencyKey("cancel-INV-001"))
+		c.Order.VoidTransaction(context.Background(), "INV-001")
+		if len(*keys) != 2 || (*keys)[0] != "cancel-INV-001" || (*keys)[1] == (*keys)[0] {
+			t.Errorf("expected the supplied key and a distinct key for the void, got %q", *keys)
+		}
+	})
+
+	t.Run("concurrent calls", func(t *testing.T) {
+		release := make(chan struct{})
+		var requests int32
+		c, ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			<-release
+			w.Write([]byte(`{"message":"cancelled"}`))
+		})
+		defer ts.Close()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := c.Order.Cancel(context.Background(), "INV-001"); err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+			}()
+		}
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		if n := atomic.LoadInt32(&requests); n != 1 {
+			t.Errorf("expected 1 request, got %d", n)
+		}
+	})
+
+	t.Run("outcome expires", func(t *testing.T) {
+		c, keys, closeFn := newMutationServer(t, 200, 200)
+		defer closeFn()
+		now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+		c.mutations.now = func() time.Time { return now }
+
+		c.Order.Cancel(context.Background(), "INV-001")
+		now = now.Add(25 * time.Hour)
+		c.Order.Cancel(context.Background(), "INV-001")
+		if len(*keys) != 2 {
+			t.Errorf("expected a new request after the retention period, got %d", len(*keys))
+		}
+	})
+
+	t.Run("expired outcomes are pruned", func(t *testing.T) {
+		c, _, closeFn := newMutationServer(t, 200, 200, 200)
+		defer closeFn()
+		now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+		c.mutations.now = func() time.Time { return now }
+
+		c.Order.Cancel(context.Background(), "INV-001")
+		now = now.Add(30 * time.Minute)
+		c.Order.Cancel(context.Background(), "INV-002")
+		now = now.Add(24 * time.Hour)
+		c.Order.Cancel(context.Background(), "INV-003")
+		if _, ok := c.mutations.entries["order/cancel:INV-001"]; ok {
+			t.Error("expected the expired outcome to be pruned")
+		}
+		if len(c.mutations.entries) != 2 {
+			t.Errorf("expected 2 remembered outcomes, got %d", len(c.mutations.entries))
+		}
+	})
+}
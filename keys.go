@@ -0,0 +1,85 @@
+package sepay
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"time"
+)
+
+// defaultSecretKeyID is the ID reported for Config.SecretKey when
+// Config.SecretKeyID is empty.
+const defaultSecretKeyID = "active"
+
+// ErrInvalidSignature is returned when a checkout signature does not match
+// any of the merchant's verification keys.
+var ErrInvalidSignature = errors.New("sepay: invalid signature")
+
+// VerificationKey is a secret key that is still accepted when verifying
+// inbound signatures and webhook secrets, typically a previous SecretKey
+// during a key rotation.
+type VerificationKey struct {
+	// ID identifies the key in verification results, e.g. "2024-03".
+	ID string
+	// Secret is the secret key.
+	Secret string
+	// ExpiresAt, if set, is when the key stops being accepted.
+	ExpiresAt time.Time
+}
+
+// validAt reports whether the key is accepted at t.
+func (k VerificationKey) validAt(t time.Time) bool {
+	return k.ExpiresAt.IsZero() || t.Before(k.ExpiresAt)
+}
+
+// verificationKeys returns the keys accepted when verifying: the active
+// secret key first, then PreviousKeys.
+func (c *Config) verificationKeys(ctx context.Context) ([]VerificationKey, error) {
+	secret, err := c.secretKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]VerificationKey, 0, 1+len(c.PreviousKeys))
+	keys = append(keys, VerificationKey{ID: c.SecretKeyID, Secret: secret})
+	return append(keys, c.PreviousKeys...), nil
+}
+
+// validateKeys checks the key IDs and previous keys of cfg.
+func validateKeys(cfg *Config) error {
+	seen := map[string]bool{cfg.SecretKeyID: true}
+	for _, k := range cfg.PreviousKeys {
+		if k.ID == "" {
+			return &ConfigError{Field: "PreviousKeys", Message: "key ID must not be empty"}
+		}
+		if k.Secret == "" {
+			return &ConfigError{Field: "PreviousKeys", Message: "secret of key " + k.ID + " must not be empty"}
+		}
+		if seen[k.ID] {
+			return &ConfigError{Field: "PreviousKeys", Message: "duplicate key ID " + k.ID}
+		}
+		seen[k.ID] = true
+	}
+	return nil
+}
+
+// matchKey returns the ID of the first key valid at now whose derived value
+// equals provided. derive maps a secret to the value expected from its
+// holder, e.g. a signature. Every key is compared, in constant time over
+// hashes of both values, so the timing reveals neither which key matched
+// nor the key length.
+func matchKey(keys []VerificationKey, now time.Time, provided string, derive func(secret string) string) (string, bool) {
+	got := sha256.Sum256([]byte(provided))
+	var matched string
+	var ok bool
+	for _, k := range keys {
+		if k.Secret == "" || !k.validAt(now) {
+			continue
+		}
+		want := sha256.Sum256([]byte(derive(k.Secret)))
+		if subtle.ConstantTimeCompare(want[:], got[:]) == 1 && !ok {
+			matched, ok = k.ID, true
+		}
+	}
+	return matched, ok
+}
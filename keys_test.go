@@ -0,0 +1,144 @@
+package sepay
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testRotationNow = time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+func newRotatingClient(t *testing.T) *Client {
+	t.Helper()
+	c, err := NewClient(Config{
+		Env:         Sandbox,
+		MerchantID:  "merchant123",
+		SecretKey:   "new-secret",
+		SecretKeyID: "2024-03",
+		PreviousKeys: []VerificationKey{
+			{ID: "2024-02", Secret: "old-secret", ExpiresAt: testRotationNow.Add(time.Hour)},
+			{ID: "2024-01", Secret: "older-secret", ExpiresAt: testRotationNow},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c.now = func() time.Time { return testRotationNow }
+	return c
+}
+
+func TestNewClient_Keys(t *testing.T) {
+	c, err := NewClient(Config{Env: Sandbox, MerchantID: "merchant123", SecretKey: "secret456"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.config.SecretKeyID != "active" {
+		t.Errorf("expected default SecretKeyID %q, got %q", "active", c.config.SecretKeyID)
+	}
+
+	tests := []struct {
+		name string
+		keys []VerificationKey
+	}{
+		{"empty ID", []VerificationKey{{Secret: "old"}}},
+		{"empty secret", []VerificationKey{{ID: "old"}}},
+		{"duplicate ID", []VerificationKey{{ID: "old", Secret: "a"}, {ID: "old", Secret: "b"}}},
+		{"ID of active key", []VerificationKey{{ID: "active", Secret: "old"}}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewClient(Config{Env: Sandbox, MerchantID: "merchant123", SecretKey: "secret456", PreviousKeys: tc.keys})
+			var cfgErr *ConfigError
+			if !errors.As(err, &cfgErr) || cfgErr.Field != "PreviousKeys" {
+				t.Errorf("expected PreviousKeys *ConfigError, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckoutService_VerifySignature(t *testing.T) {
+	c := newRotatingClient(t)
+	values := c.Checkout.InitOneTimePaymentFields(OnetimePaymentFields{
+		OrderInvoiceNumber: "INV-001",
+		OrderAmount:        VND(100000),
+		OrderDescription:   "Test payment",
+	}).FormValues()
+
+	if values["signature"] != signFields(values, "new-secret") {
+		t.Error("expected fields to be signed with the active key")
+	}
+
+	tests := []struct {
+		secret string
+		keyID  string
+	}{
+		{"new-secret", "2024-03"},
+		{"old-secret", "2024-02"},
+		{"older-secret", ""}, // expired
+		{"unknown", ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.secret, func(t *testing.T) {
+			values["signature"] = signFields(values, tc.secret)
+			keyID, err := c.Checkout.VerifySignature(values)
+			if tc.keyID == "" {
+				if !errors.Is(err, ErrInvalidSignature) {
+					t.Errorf("expected ErrInvalidSignature, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if keyID != tc.keyID {
+				t.Errorf("expected key %q, got %q", tc.keyID, keyID)
+			}
+		})
+	}
+
+	values["signature"] = signFields(values, "new-secret")
+	values["order_amount"] = "1"
+	if _, err := c.Checkout.VerifySignature(values); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for tampered fields, got %v", err)
+	}
+	delete(values, "signature")
+	if _, err := c.Checkout.VerifySignature(values); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for missing signature, got %v", err)
+	}
+}
+
+func TestWebhookHandler_KeyRotation(t *testing.T) {
+	h := newRotatingClient(t).NewWebhookHandler()
+	h.now = func() time.Time { return testRotationNow }
+	var keyID string
+	h.On(EventOrderPaid, func(ctx context.Context, event *WebhookEvent) error {
+		keyID = event.KeyID
+		return nil
+	})
+
+	tests := []struct {
+		secret string
+		status int
+		keyID  string
+	}{
+		{"new-secret", http.StatusOK, "2024-03"},
+		{"old-secret", http.StatusOK, "2024-02"},
+		{"older-secret", http.StatusUnauthorized, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.secret, func(t *testing.T) {
+			keyID = ""
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, newWebhookRequest(testOrderPaidPayload, tc.secret))
+			if rec.Code != tc.status {
+				t.Errorf("expected status %d, got %d", tc.status, rec.Code)
+			}
+			if keyID != tc.keyID {
+				t.Errorf("expected key %q, got %q", tc.keyID, keyID)
+			}
+		})
+	}
+}
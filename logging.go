@@ -0,0 +1,213 @@
+package sepay
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// redacted replaces secrets in formatted and logged values.
+const redacted = "[REDACTED]"
+
+// RequestIDHeader is the response header carrying SePay's request ID, which
+// is logged with every request to help SePay support trace it.
+const RequestIDHeader = "X-Request-Id"
+
+// loggingMiddleware logs every attempt at an API request. Successful
+// attempts are logged at level, failed ones at least at slog.LevelWarn.
+// Request and response headers are never logged, so the Authorization
+// header cannot leak.
+func loggingMiddleware(logger *slog.Logger, level slog.Level) Middleware {
+	return func(next RequestHandler) RequestHandler {
+		return func(req *http.Request, info RequestInfo) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next(req, info)
+
+			attrs := []slog.Attr{
+				slog.String("operation", info.Operation()),
+				slog.String("http_method", req.Method),
+				slog.String("endpoint", req.URL.Path),
+				slog.Int("attempt", info.Attempt),
+				slog.Duration("latency", time.Since(start)),
+			}
+			if info.InvoiceNumber != "" {
+				attrs = append(attrs, slog.String("invoice_number", info.InvoiceNumber))
+			}
+			lvl := level
+			if resp != nil {
+				attrs = append(attrs, slog.Int("status", resp.StatusCode))
+				if id := resp.Header.Get(RequestIDHeader); id != "" {
+					attrs = append(attrs, slog.String("request_id", id))
+				}
+				if resp.StatusCode >= 400 && lvl < slog.LevelWarn {
+					lvl = slog.LevelWarn
+				}
+			}
+			if err != nil {
+				attrs = append(attrs, slog.String("error", err.Error()))
+				if lvl < slog.LevelWarn {
+					lvl = slog.LevelWarn
+				}
+			}
+			logger.LogAttrs(req.Context(), lvl, "sepay: api request", attrs...)
+			return resp, err
+		}
+	}
+}
+
+// formatDirective rebuilds the directive fmt called a Formatter with, so
+// that a redacted copy can be printed the same way.
+func formatDirective(f fmt.State, verb rune) string {
+	var b strings.Builder
+	b.WriteByte('%')
+	for _, flag := range "+-# 0" {
+		if f.Flag(int(flag)) {
+			b.WriteRune(flag)
+		}
+	}
+	if w, ok := f.Width(); ok {
+		fmt.Fprint(&b, w)
+	}
+	if p, ok := f.Precision(); ok {
+		fmt.Fprintf(&b, ".%d", p)
+	}
+	b.WriteRune(verb)
+	return b.String()
+}
+
+// formatRedacted prints v, a redacted copy of a value of the named type
+// converted to a type without methods, with the directive fmt called Format
+// with. For %#v, the name of the method-less type is replaced with typeName.
+func formatRedacted(f fmt.State, verb rune, typeName string, v any) {
+	if verb == 'v' && f.Flag('#') {
+		s := fmt.Sprintf("%#v", v)
+		fmt.Fprint(f, typeName+strings.TrimPrefix(s, fmt.Sprintf("%T", v)))
+		return
+	}
+	fmt.Fprintf(f, formatDirective(f, verb), v)
+}
+
+// redact returns redacted for a non-empty secret.
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redacted
+}
+
+// Format prints the config with its secret key redacted.
+func (c Config) Format(f fmt.State, verb rune) {
+	type config Config // without methods
+	c.SecretKey = redact(c.SecretKey)
+	formatRedacted(f, verb, "sepay.Config", config(c))
+}
+
+// LogValue logs the config with its secret key redacted.
+func (c Config) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("env", string(c.Env)),
+		slog.String("merchant_id", c.MerchantID),
+		slog.String("secret_key", redact(c.SecretKey)),
+		slog.String("secret_key_id", c.SecretKeyID),
+		slog.Int("previous_keys", len(c.PreviousKeys)),
+		slog.String("api_version", string(c.APIVersion)),
+		slog.String("checkout_version", string(c.CheckoutVersion)),
+	)
+}
+
+// Format prints the key with its secret redacted.
+func (k VerificationKey) Format(f fmt.State, verb rune) {
+	type key VerificationKey // without methods
+	k.Secret = redact(k.Secret)
+	formatRedacted(f, verb, "sepay.VerificationKey", key(k))
+}
+
+// LogValue logs the key with its secret redacted.
+func (k VerificationKey) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("id", k.ID),
+		slog.String("secret", redact(k.Secret)),
+		slog.Time("expires_at", k.ExpiresAt),
+	)
+}
+
+// String returns a redacted placeholder instead of the key.
+func (s StaticSecret) String() string {
+	return redact(string(s))
+}
+
+// Format prints a redacted placeholder instead of the key, whatever the verb.
+func (s StaticSecret) Format(f fmt.State, verb rune) {
+	if verb == 'v' && f.Flag('#') {
+		fmt.Fprint(f, s.GoString())
+		return
+	}
+	fmt.Fprintf(f, formatDirective(f, verb), s.String())
+}
+
+// GoString returns a redacted placeholder instead of the key.
+func (s StaticSecret) GoString() string {
+	return `sepay.StaticSecret("` + redact(string(s)) + `")`
+}
+
+// LogValue logs a redacted placeholder instead of the key.
+func (s StaticSecret) LogValue() slog.Value {
+	return slog.StringValue(redact(string(s)))
+}
+
+// String describes the provider without its cached key.
+func (f *FileSecret) String() string {
+	return "sepay.FileSecret(" + f.path + ")"
+}
+
+// GoString describes the provider without its cached key.
+func (f *FileSecret) GoString() string {
+	return f.String()
+}
+
+// String describes the provider without its cached key.
+func (c *CachedSecret) String() string {
+	return fmt.Sprintf("sepay.CachedSecret(%v)", c.provider)
+}
+
+// GoString describes the provider without its cached key.
+func (c *CachedSecret) GoString() string {
+	return c.String()
+}
+
+// Format prints the client's environment and merchant, but none of its
+// internals or secrets.
+func (c *Client) Format(f fmt.State, verb rune) {
+	if c == nil {
+		fmt.Fprint(f, "<nil>")
+		return
+	}
+	fmt.Fprintf(f, "sepay.Client{Env:%s MerchantID:%s}", c.config.Env, c.config.MerchantID)
+}
+
+// LogValue logs the client's configuration with its secret redacted.
+func (c *Client) LogValue() slog.Value {
+	return c.config.LogValue()
+}
+
+// Format prints the fields with the signature redacted.
+func (f SignedCheckoutFields) Format(s fmt.State, verb rune) {
+	type fields SignedCheckoutFields // without methods
+	f.Signature = redact(f.Signature)
+	formatRedacted(s, verb, "sepay.SignedCheckoutFields", fields(f))
+}
+
+// LogValue logs the fields with the signature redacted.
+func (f SignedCheckoutFields) LogValue() slog.Value {
+	formFields := f.FormFields()
+	attrs := make([]slog.Attr, 0, len(formFields))
+	for _, field := range formFields {
+		if field.Name == "signature" {
+			field.Value = redact(field.Value)
+		}
+		attrs = append(attrs, slog.String(field.Name, field.Value))
+	}
+	return slog.GroupValue(attrs...)
+}
@@ -0,0 +1,136 @@
+package sepay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClient_Logger(t *testing.T) {
+	var buf bytes.Buffer
+	var requests int
+	c, server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set(RequestIDHeader, fmt.Sprintf("req-%d", requests))
+		if requests == 2 {
+			w.WriteHeader(404)
+			return
+		}
+		w.Write([]byte(`{"data":{"order_invoice_number":"INV-001"}}`))
+	})
+	defer server.Close()
+	c.Use(loggingMiddleware(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})), slog.LevelDebug))
+
+	c.Order.Retrieve(context.Background(), "INV-001")
+	c.Order.Retrieve(context.Background(), "INV-002")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 records, got %d: %s", len(lines), buf.String())
+	}
+	var records []map[string]any
+	for _, line := range lines {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		records = append(records, record)
+	}
+
+	want := map[string]any{
+		"level":          "DEBUG",
+		"operation":      "Order.Retrieve",
+		"http_method":    "GET",
+		"endpoint":       "/order/detail/INV-001",
+		"status":         float64(200),
+		"attempt":        float64(1),
+		"invoice_number": "INV-001",
+		"request_id":     "req-1",
+	}
+	for k, v := range want {
+		if records[0][k] != v {
+			t.Errorf("expected %s %v, got %v", k, v, records[0][k])
+		}
+	}
+	if _, ok := records[0]["latency"]; !ok {
+		t.Error("expected latency")
+	}
+	if records[1]["level"] != "WARN" || records[1]["status"] != float64(404) {
+		t.Errorf("expected failed request at WARN, got %v", records[1])
+	}
+
+	for _, secret := range []string{"secret456", "Basic", "Authorization"} {
+		if strings.Contains(buf.String(), secret) {
+			t.Errorf("expected %q not to be logged: %s", secret, buf.String())
+		}
+	}
+}
+
+func TestRedaction(t *testing.T) {
+	cfg := Config{
+		Env:          Sandbox,
+		MerchantID:   "merchant123",
+		SecretKey:    "secret456",
+		PreviousKeys: []VerificationKey{{ID: "old", Secret: "oldsecret789"}},
+	}
+	c, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	signed := c.Checkout.InitOneTimePaymentFields(OnetimePaymentFields{
+		OrderInvoiceNumber: "INV-001",
+		OrderAmount:        VND(100000),
+		OrderDescription:   "Test payment",
+	})
+
+	var buf bytes.Buffer
+	for _, verb := range []string{"%v", "%+v", "%#v", "%s", "%q", "%x", "%d"} {
+		fmt.Fprintf(&buf, verb+"\n", cfg)
+		fmt.Fprintf(&buf, verb+"\n", c.config)
+		fmt.Fprintf(&buf, verb+"\n", c)
+		fmt.Fprintf(&buf, verb+"\n", *signed)
+		fmt.Fprintf(&buf, verb+"\n", signed)
+	}
+	cached := NewCachedSecret(StaticSecret("secret456"), time.Minute)
+	cached.SecretKey(context.Background())
+	for _, verb := range []string{"%v", "%+v", "%#v"} {
+		fmt.Fprintf(&buf, verb+"\n", cached)
+	}
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	logger.Info("values", "config", cfg, "client", c, "fields", signed)
+
+	out := buf.String()
+	for _, secret := range []string{"secret456", "oldsecret789", signed.Signature, fmt.Sprintf("%x", "secret456")} {
+		if strings.Contains(out, secret) {
+			t.Errorf("expected %q to be redacted:\n%s", secret, out)
+		}
+	}
+	for _, visible := range []string{"merchant123", "INV-001", redacted} {
+		if !strings.Contains(out, visible) {
+			t.Errorf("expected %q in output:\n%s", visible, out)
+		}
+	}
+
+	goSyntax := fmt.Sprintf("%#v %#v", c.config, *signed)
+	for _, name := range []string{"sepay.Config{", "sepay.VerificationKey{", `sepay.StaticSecret("[REDACTED]")`, "sepay.SignedCheckoutFields{"} {
+		if !strings.Contains(goSyntax, name) {
+			t.Errorf("expected %s in %%#v output:\n%s", name, goSyntax)
+		}
+	}
+	for _, name := range []string{"sepay.config", "sepay.key", "sepay.fields"} {
+		if strings.Contains(goSyntax, name) {
+			t.Errorf("expected no internal type %s in %%#v output:\n%s", name, goSyntax)
+		}
+	}
+
+	data, _ := json.Marshal(signed)
+	if !strings.Contains(string(data), signed.Signature) {
+		t.Error("expected the signature to remain in JSON")
+	}
+}
@@ -0,0 +1,66 @@
+package sepay
+
+import (
+	"net/http"
+	"strings"
+)
+
+// RequestInfo describes the SDK operation an API request is made for.
+type RequestInfo struct {
+	// Service is the service the operation belongs to, e.g. "Order".
+	Service string
+	// Method is the name of the operation, e.g. "Retrieve". Decoding
+	// variants count as the operation they wrap, e.g. RetrieveOrder as
+	// Retrieve.
+	Method string
+	// InvoiceNumber is the order invoice number the operation is for, if
+	// any.
+	InvoiceNumber string
+	// Attempt is the number of the attempt, starting at 1 and increasing
+	// with every retry.
+	Attempt int
+}
+
+// Operation returns the operation name used by RateLimit and
+// CircuitBreakerSettings, e.g. "Order.Retrieve".
+func (i RequestInfo) Operation() string {
+	return i.Service + "." + i.Method
+}
+
+// RequestHandler sends a single attempt at an API request.
+type RequestHandler func(req *http.Request, info RequestInfo) (*http.Response, error)
+
+// Middleware wraps a RequestHandler, e.g. to log, measure, modify or fail
+// requests. A middleware may return an error or a response of its own
+// instead of calling next.
+type Middleware func(next RequestHandler) RequestHandler
+
+// Use appends middleware to the chain every API request goes through. The
+// first middleware added is the outermost. Each retry attempt passes through
+// the chain again, with the same RequestInfo apart from Attempt. Use must be
+// called before the client is used concurrently.
+func (c *Client) Use(middleware ...Middleware) {
+	c.middleware = append(c.middleware, middleware...)
+}
+
+// requestHandler returns the client's HTTP client wrapped in its middleware.
+func (c *Client) requestHandler() RequestHandler {
+	h := RequestHandler(func(req *http.Request, _ RequestInfo) (*http.Response, error) {
+		return c.httpClient.Do(req)
+	})
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		h = c.middleware[i](h)
+	}
+	return h
+}
+
+// info returns the RequestInfo for the given attempt at r.
+func (r *apiRequest) info(attempt int) RequestInfo {
+	service, method, _ := strings.Cut(r.operation, ".")
+	return RequestInfo{
+		Service:       service,
+		Method:        method,
+		InvoiceNumber: r.invoiceNumber,
+		Attempt:       attempt,
+	}
+}
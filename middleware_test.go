@@ -0,0 +1,87 @@
+package sepay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestClient_Use(t *testing.T) {
+	var requests int
+	c, ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(503)
+			return
+		}
+		fmt.Fprintf(w, `{"data":{"order_invoice_number":%q}}`, r.Header.Get("X-Tenant"))
+	})
+	defer ts.Close()
+	c.config.Retry = &RetryPolicy{MaxAttempts: 2}
+	c.sleep = func(context.Context, time.Duration) error { return nil }
+
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next RequestHandler) RequestHandler {
+			return func(req *http.Request, info RequestInfo) (*http.Response, error) {
+				calls = append(calls, fmt.Sprintf("%s %s %s %d", name, info.Operation(), info.InvoiceNumber, info.Attempt))
+				return next(req, info)
+			}
+		}
+	}
+	c.Use(trace("outer"), trace("inner"))
+	c.Use(func(next RequestHandler) RequestHandler {
+		return func(req *http.Request, info RequestInfo) (*http.Response, error) {
+			req.Header.Set("X-Tenant", "tenant-1")
+			return next(req, info)
+		}
+	})
+
+	order, _, err := c.Order.RetrieveOrder(context.Background(), "INV-001")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if order.OrderInvoiceNumber != "tenant-1" {
+		t.Errorf("expected the middleware's header to reach the server, got %q", order.OrderInvoiceNumber)
+	}
+	want := []string{
+		"outer Order.Retrieve INV-001 1",
+		"inner Order.Retrieve INV-001 1",
+		"outer Order.Retrieve INV-001 2",
+		"inner Order.Retrieve INV-001 2",
+	}
+	if fmt.Sprint(calls) != fmt.Sprint(want) {
+		t.Errorf("expected calls %v, got %v", want, calls)
+	}
+}
+
+func TestClient_Use_FaultInjection(t *testing.T) {
+	var requests int
+	c, ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	})
+	defer ts.Close()
+
+	errInjected := errors.New("injected")
+	c.Use(func(next RequestHandler) RequestHandler {
+		return func(req *http.Request, info RequestInfo) (*http.Response, error) {
+			if info.Service == "Order" && info.Method == "Cancel" {
+				return nil, errInjected
+			}
+			return next(req, info)
+		}
+	})
+
+	if _, err := c.Order.Cancel(context.Background(), "INV-001"); !errors.Is(err, errInjected) {
+		t.Errorf("expected injected error, got %v", err)
+	}
+	if _, err := c.Order.All(context.Background(), nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("expected only All to reach the server, got %d requests", requests)
+	}
+}
@@ -2,20 +2,296 @@ package sepay
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"net/url"
+	"time"
 )
 
+// Order represents an order in the SePay payment gateway.
+type Order struct {
+	ID                 string
+	OrderID            string
+	OrderInvoiceNumber string
+	OrderStatus        OrderStatus
+	OrderAmount        Amount
+	OrderCurrency      string
+	OrderDescription   string
+	PaymentMethod      PaymentMethod
+	CustomerID         string
+	CustomData         string
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	Transactions       []Transaction
+	// Agreement is the recurring payment agreement or saved card created by
+	// the order, if any.
+	Agreement *Agreement
+}
+
+// UnmarshalJSON decodes an order as returned by the SePay API. Amounts may be
+// encoded as JSON numbers or strings and are interpreted in the order
+// currency, and timestamps use the SePay layout.
+func (o *Order) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		ID                 flexString      `json:"id"`
+		OrderID            flexString      `json:"order_id"`
+		OrderInvoiceNumber string          `json:"order_invoice_number"`
+		OrderStatus        OrderStatus     `json:"order_status"`
+		OrderAmount        json.Number     `json:"order_amount"`
+		OrderCurrency      string          `json:"order_currency"`
+		OrderDescription   string          `json:"order_description"`
+		PaymentMethod      PaymentMethod   `json:"payment_method"`
+		CustomerID         flexString      `json:"customer_id"`
+		CustomData         json.RawMessage `json:"custom_data"`
+		CreatedAt          string          `json:"created_at"`
+		UpdatedAt          string          `json:"updated_at"`
+		Transactions       []Transaction   `json:"transactions"`
+		Agreement          json.RawMessage `json:"agreement"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	amount, err := parseAmountNumber(raw.OrderAmount, raw.OrderCurrency)
+	if err != nil {
+		return fmt.Errorf("sepay: decoding order_amount: %w", err)
+	}
+	createdAt, err := parseTime(raw.CreatedAt)
+	if err != nil {
+		return err
+	}
+	updatedAt, err := parseTime(raw.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	var agreement *Agreement
+	if len(raw.Agreement) > 0 && string(raw.Agreement) != "null" {
+		// Seed the amount currency so it is parsed with the right scale.
+		agreement = &Agreement{AmountPerPayment: NewAmount(0, raw.OrderCurrency)}
+		if err := json.Unmarshal(raw.Agreement, agreement); err != nil {
+			return fmt.Errorf("sepay: decoding agreement: %w", err)
+		}
+	}
+
+	*o = Order{
+		ID:                 string(raw.ID),
+		OrderID:            string(raw.OrderID),
+		OrderInvoiceNumber: raw.OrderInvoiceNumber,
+		OrderStatus:        raw.OrderStatus,
+		OrderAmount:        amount,
+		OrderCurrency:      raw.OrderCurrency,
+		OrderDescription:   raw.OrderDescription,
+		PaymentMethod:      raw.PaymentMethod,
+		CustomerID:         string(raw.CustomerID),
+		CustomData:         decodeCustomData(raw.CustomData),
+		CreatedAt:          createdAt,
+		UpdatedAt:          updatedAt,
+		Transactions:       raw.Transactions,
+		Agreement:          agreement,
+	}
+	return nil
+}
+
+// Agreement is a recurring payment agreement or saved card established
+// through a RECURRING checkout or a card VERIFY checkout.
+type Agreement struct {
+	AgreementID      string             `json:"agreement_id"`
+	AgreementName    string             `json:"agreement_name"`
+	AgreementType    AgreementType      `json:"agreement_type"`
+	AgreementStatus  string             `json:"agreement_status"`
+	PaymentFrequency AgreementFrequency `json:"agreement_payment_frequency"`
+	AmountPerPayment Amount             `json:"agreement_amount_per_payment"`
+	// CardToken references the saved card for merchant-initiated payments.
+	CardToken  string `json:"card_token"`
+	CardNumber string `json:"card_number"`
+	CardBrand  string `json:"card_brand"`
+	CardExpiry string `json:"card_expiry"`
+}
+
+// Transaction represents a payment attempt recorded against an order.
+type Transaction struct {
+	ID                   string
+	TransactionID        string
+	PaymentMethod        PaymentMethod
+	TransactionType      string
+	TransactionDate      time.Time
+	TransactionStatus    string
+	TransactionAmount    Amount
+	TransactionCurrency  string
+	AuthenticationStatus string
+	CardNumber           string
+	CardHolderName       string
+	CardExpiry           string
+	CardFundingMethod    string
+	CardBrand            string
+}
+
+// UnmarshalJSON decodes a transaction as returned by the SePay API.
+func (t *Transaction) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		ID                   flexString    `json:"id"`
+		TransactionID        flexString    `json:"transaction_id"`
+		PaymentMethod        PaymentMethod `json:"payment_method"`
+		TransactionType      string        `json:"transaction_type"`
+		TransactionDate      string        `json:"transaction_date"`
+		TransactionStatus    string        `json:"transaction_status"`
+		TransactionAmount    json.Number   `json:"transaction_amount"`
+		TransactionCurrency  string        `json:"transaction_currency"`
+		AuthenticationStatus string        `json:"authentication_status"`
+		CardNumber           string        `json:"card_number"`
+		CardHolderName       string        `json:"card_holder_name"`
+		CardExpiry           string        `json:"card_expiry"`
+		CardFundingMethod    string        `json:"card_funding_method"`
+		CardBrand            string        `json:"card_brand"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	amount, err := parseAmountNumber(raw.TransactionAmount, raw.TransactionCurrency)
+	if err != nil {
+		return fmt.Errorf("sepay: decoding transaction_amount: %w", err)
+	}
+	date, err := parseTime(raw.TransactionDate)
+	if err != nil {
+		return err
+	}
+
+	*t = Transaction{
+		ID:                   string(raw.ID),
+		TransactionID:        string(raw.TransactionID),
+		PaymentMethod:        raw.PaymentMethod,
+		TransactionType:      raw.TransactionType,
+		TransactionDate:      date,
+		TransactionStatus:    raw.TransactionStatus,
+		TransactionAmount:    amount,
+		TransactionCurrency:  raw.TransactionCurrency,
+		AuthenticationStatus: raw.AuthenticationStatus,
+		CardNumber:           raw.CardNumber,
+		CardHolderName:       raw.CardHolderName,
+		CardExpiry:           raw.CardExpiry,
+		CardFundingMethod:    raw.CardFundingMethod,
+		CardBrand:            raw.CardBrand,
+	}
+	return nil
+}
+
+// flexString decodes a JSON string or number into a string. SePay is not
+// consistent about the type of identifiers across endpoints.
+type flexString string
+
+func (s *flexString) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*s = ""
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var v string
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		*s = flexString(v)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*s = flexString(n)
+	return nil
+}
+
+// decodeCustomData returns custom_data as a string. SePay echoes the value
+// given at checkout, but encodes an absent value as an empty array.
+func decodeCustomData(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" || string(raw) == "[]" {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
+
+// OrderStatus represents the status of an order.
+type OrderStatus string
+
+const (
+	OrderStatusPending                 OrderStatus = "PENDING"
+	OrderStatusCaptured                OrderStatus = "CAPTURED"
+	OrderStatusCompleted               OrderStatus = "COMPLETED"
+	OrderStatusAuthenticationNotNeeded OrderStatus = "AUTHENTICATION_NOT_NEEDED"
+	OrderStatusCancelled               OrderStatus = "CANCELLED"
+	OrderStatusVoided                  OrderStatus = "VOIDED"
+)
+
+// Valid reports whether s is a known order status.
+func (s OrderStatus) Valid() bool {
+	switch s {
+	case OrderStatusPending, OrderStatusCaptured, OrderStatusCompleted,
+		OrderStatusAuthenticationNotNeeded, OrderStatusCancelled, OrderStatusVoided:
+		return true
+	}
+	return false
+}
+
+// SortDirection represents the sort order of a listing.
+type SortDirection string
+
+const (
+	SortAsc  SortDirection = "asc"
+	SortDesc SortDirection = "desc"
+)
+
+// Valid reports whether d is a known sort direction.
+func (d SortDirection) Valid() bool {
+	return d == SortAsc || d == SortDesc
+}
+
 // OrderQueryParams holds optional query parameters for listing orders.
+// Zero values of the typed fields are omitted from the query.
 type OrderQueryParams struct {
-	PerPage       *int
-	Q             *string
-	OrderStatus   *string
-	CreatedAt     *string
-	FromCreatedAt *string
-	ToCreatedAt   *string
+	Page    *int
+	PerPage *int
+	Q       *string
+	// OrderStatus filters orders by status.
+	OrderStatus OrderStatus
+	// CreatedAt filters orders created on the same calendar day, in the
+	// SePay timezone (UTC+7).
+	CreatedAt time.Time
+	// FromCreatedAt and ToCreatedAt filter orders created within the
+	// inclusive range of calendar days, in the SePay timezone. Only the
+	// date is sent.
+	FromCreatedAt time.Time
+	ToCreatedAt   time.Time
 	CustomerID    *string
-	SortCreatedAt *string
+	SortCreatedAt SortDirection
+}
+
+// Validate checks the query parameters and returns a *ValidationError
+// describing the first invalid field, or nil.
+func (p *OrderQueryParams) Validate() error {
+	if p == nil {
+		return nil
+	}
+	if p.Page != nil && *p.Page < 1 {
+		return &ValidationError{Field: "Page", Message: "must be at least 1"}
+	}
+	if p.PerPage != nil && *p.PerPage < 1 {
+		return &ValidationError{Field: "PerPage", Message: "must be positive"}
+	}
+	if p.OrderStatus != "" && !p.OrderStatus.Valid() {
+		return &ValidationError{Field: "OrderStatus", Message: fmt.Sprintf("unknown status %q", p.OrderStatus)}
+	}
+	if p.SortCreatedAt != "" && !p.SortCreatedAt.Valid() {
+		return &ValidationError{Field: "SortCreatedAt", Message: fmt.Sprintf("unknown sort direction %q", p.SortCreatedAt)}
+	}
+	if !p.FromCreatedAt.IsZero() && !p.ToCreatedAt.IsZero() && formatDate(p.FromCreatedAt) > formatDate(p.ToCreatedAt) {
+		return &ValidationError{Field: "FromCreatedAt", Message: "must not be after ToCreatedAt"}
+	}
+	return nil
 }
 
 func (p *OrderQueryParams) toValues() url.Values {
@@ -23,29 +299,32 @@ func (p *OrderQueryParams) toValues() url.Values {
 		return nil
 	}
 	v := url.Values{}
+	if p.Page != nil {
+		v.Set("page", fmt.Sprintf("%d", *p.Page))
+	}
 	if p.PerPage != nil {
 		v.Set("per_page", fmt.Sprintf("%d", *p.PerPage))
 	}
 	if p.Q != nil {
 		v.Set("q", *p.Q)
 	}
-	if p.OrderStatus != nil {
-		v.Set("order_status", *p.OrderStatus)
+	if p.OrderStatus != "" {
+		v.Set("order_status", string(p.OrderStatus))
 	}
-	if p.CreatedAt != nil {
-		v.Set("created_at", *p.CreatedAt)
+	if !p.CreatedAt.IsZero() {
+		v.Set("created_at", formatDate(p.CreatedAt))
 	}
-	if p.FromCreatedAt != nil {
-		v.Set("from_created_at", *p.FromCreatedAt)
+	if !p.FromCreatedAt.IsZero() {
+		v.Set("from_created_at", formatDate(p.FromCreatedAt))
 	}
-	if p.ToCreatedAt != nil {
-		v.Set("to_created_at", *p.ToCreatedAt)
+	if !p.ToCreatedAt.IsZero() {
+		v.Set("to_created_at", formatDate(p.ToCreatedAt))
 	}
 	if p.CustomerID != nil {
 		v.Set("customer_id", *p.CustomerID)
 	}
-	if p.SortCreatedAt != nil {
-		v.Set("sort[created_at]", *p.SortCreatedAt)
+	if p.SortCreatedAt != "" {
+		v.Set("sort[created_at]", string(p.SortCreatedAt))
 	}
 	if len(v) == 0 {
 		return nil
@@ -53,29 +332,110 @@ func (p *OrderQueryParams) toValues() url.Values {
 	return v
 }
 
+// Pagination describes the position of a page within a paginated listing.
+type Pagination struct {
+	Total       int `json:"total"`
+	PerPage     int `json:"per_page"`
+	CurrentPage int `json:"current_page"`
+	LastPage    int `json:"last_page"`
+	// NextPage is the number of the following page, or 0 if this is the
+	// last page.
+	NextPage int `json:"-"`
+}
+
+// OrderList is a single page of orders returned by OrderService.ListOrders.
+type OrderList struct {
+	Orders     []Order
+	Pagination Pagination
+}
+
+// UnmarshalJSON decodes a paginated order listing from its
+// {"data": [...], "meta": {...}} envelope.
+func (l *OrderList) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Data []Order    `json:"data"`
+		Meta Pagination `json:"meta"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw.Meta.CurrentPage > 0 && raw.Meta.CurrentPage < raw.Meta.LastPage {
+		raw.Meta.NextPage = raw.Meta.CurrentPage + 1
+	}
+	*l = OrderList{Orders: raw.Data, Pagination: raw.Meta}
+	return nil
+}
+
 // OrderService provides access to the order-related endpoints.
 type OrderService struct {
 	api apiResource
 }
 
 // All retrieves a list of orders matching the given query parameters.
+// The parameters are validated before any request is sent.
 func (s *OrderService) All(ctx context.Context, params *OrderQueryParams) (*Response, error) {
-	return s.api.doRequest(ctx, "GET", "order", params.toValues(), nil)
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	r := newAPIRequest("Order.All", http.MethodGet, "order", nil)
+	r.query = params.toValues()
+	return s.api.doRequest(ctx, r)
+}
+
+// ListOrders retrieves a page of orders matching the given query parameters
+// and decodes it into an OrderList. Use OrderQueryParams.Page to select the
+// page and OrderList.Pagination to find the next one.
+func (s *OrderService) ListOrders(ctx context.Context, params *OrderQueryParams) (*OrderList, *Response, error) {
+	resp, err := s.All(ctx, params)
+	if err != nil {
+		return nil, resp, err
+	}
+	var list OrderList
+	if err := resp.DecodeJSON(&list); err != nil {
+		return nil, resp, fmt.Errorf("sepay: decoding order list: %w", err)
+	}
+	return &list, resp, nil
 }
 
 // Retrieve retrieves the details of a single order by its invoice number.
 func (s *OrderService) Retrieve(ctx context.Context, orderInvoiceNumber string) (*Response, error) {
-	return s.api.doRequest(ctx, "GET", "order/detail/"+orderInvoiceNumber, nil, nil)
+	r := newAPIRequest("Order.Retrieve", http.MethodGet, "order/detail/"+orderInvoiceNumber, nil)
+	r.invoiceNumber = orderInvoiceNumber
+	r.returnsOrder = true
+	return s.api.doRequest(ctx, r)
+}
+
+// RetrieveOrder retrieves a single order by its invoice number and decodes it
+// into an Order. The underlying Response is returned alongside for access to
+// headers and status.
+func (s *OrderService) RetrieveOrder(ctx context.Context, orderInvoiceNumber string) (*Order, *Response, error) {
+	resp, err := s.Retrieve(ctx, orderInvoiceNumber)
+	if err != nil {
+		return nil, resp, err
+	}
+	var order Order
+	if err := resp.decodeData(&order); err != nil {
+		return nil, resp, fmt.Errorf("sepay: decoding order: %w", err)
+	}
+	return &order, resp, nil
 }
 
 // VoidTransaction voids a transaction for the given order invoice number.
-func (s *OrderService) VoidTransaction(ctx context.Context, orderInvoiceNumber string) (*Response, error) {
+// Calls for the same invoice share an idempotency key, and once one succeeds
+// the others return its response without sending a request.
+func (s *OrderService) VoidTransaction(ctx context.Context, orderInvoiceNumber string, opts ...RequestOption) (*Response, error) {
 	body := map[string]string{"order_invoice_number": orderInvoiceNumber}
-	return s.api.doRequestJSON(ctx, "POST", "order/voidTransaction", body)
+	r := newAPIRequest("Order.VoidTransaction", http.MethodPost, "order/voidTransaction", opts)
+	r.invoiceNumber = orderInvoiceNumber
+	return s.api.doMutation(ctx, r, body, nil)
 }
 
-// Cancel cancels the order with the given invoice number.
-func (s *OrderService) Cancel(ctx context.Context, orderInvoiceNumber string) (*Response, error) {
+// Cancel cancels the order with the given invoice number, deduplicated like
+// VoidTransaction. ErrOrderAlreadyCancelled after an attempt that may have
+// been applied is reported as a synthesized 200 OK response.
+func (s *OrderService) Cancel(ctx context.Context, orderInvoiceNumber string, opts ...RequestOption) (*Response, error) {
 	body := map[string]string{"order_invoice_number": orderInvoiceNumber}
-	return s.api.doRequestJSON(ctx, "POST", "order/cancel", body)
+	r := newAPIRequest("Order.Cancel", http.MethodPost, "order/cancel", opts)
+	r.invoiceNumber = orderInvoiceNumber
+	return s.api.doMutation(ctx, r, body, ErrOrderAlreadyCancelled)
 }
@@ -0,0 +1,95 @@
+package sepay
+
+import "context"
+
+// OrderIterator walks through every order matching a query, fetching
+// successive pages from OrderService.ListOrders as needed.
+//
+//	it := client.Order.Iter(ctx, params)
+//	for it.Next() {
+//		order := it.Order()
+//		// ...
+//	}
+//	if err := it.Err(); err != nil {
+//		// ...
+//	}
+type OrderIterator struct {
+	service *OrderService
+	ctx     context.Context
+	params  OrderQueryParams
+
+	page     []Order
+	index    int
+	current  Order
+	nextPage int
+	err      error
+}
+
+// Iter returns an iterator over all orders matching the given query
+// parameters. Iteration starts at params.Page (or the first page) and stops
+// after the last page, on the first error, or when ctx is cancelled.
+func (s *OrderService) Iter(ctx context.Context, params *OrderQueryParams) *OrderIterator {
+	it := &OrderIterator{service: s, ctx: ctx, nextPage: 1}
+	if params != nil {
+		it.params = *params
+		if params.Page != nil && *params.Page > 0 {
+			it.nextPage = *params.Page
+		}
+	}
+	return it
+}
+
+// Next advances the iterator to the next order, fetching the next page when
+// the current one is exhausted. It returns false when there are no more
+// orders or an error occurred; check Err to tell the two apart.
+func (it *OrderIterator) Next() bool {
+	for it.index >= len(it.page) {
+		if it.err != nil || it.nextPage == 0 {
+			return false
+		}
+		if err := it.ctx.Err(); err != nil {
+			it.err = err
+			return false
+		}
+		if !it.fetch() {
+			return false
+		}
+	}
+	it.current = it.page[it.index]
+	it.index++
+	return true
+}
+
+// Order returns the order at the current position of the iterator.
+func (it *OrderIterator) Order() Order {
+	return it.current
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *OrderIterator) Err() error {
+	return it.err
+}
+
+// fetch loads the page numbered it.nextPage and reports whether it contained
+// any orders.
+func (it *OrderIterator) fetch() bool {
+	requested := it.nextPage
+	params := it.params
+	params.Page = Int(requested)
+
+	list, _, err := it.service.ListOrders(it.ctx, &params)
+	if err != nil {
+		it.err = err
+		return false
+	}
+
+	it.page = list.Orders
+	it.index = 0
+	it.nextPage = list.Pagination.NextPage
+	// Guard against a server that does not advance, which would otherwise
+	// loop forever.
+	if it.nextPage <= requested {
+		it.nextPage = 0
+	}
+	return len(it.page) > 0
+}
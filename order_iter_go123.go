@@ -0,0 +1,32 @@
+//go:build go1.23
+
+package sepay
+
+import (
+	"context"
+	"iter"
+)
+
+// Seq returns a sequence over all orders matching the given query parameters,
+// for use with range-over-func. Pages are fetched lazily as in Iter. If a page
+// cannot be fetched, the sequence yields the error once and stops.
+//
+//	for order, err := range client.Order.Seq(ctx, params) {
+//		if err != nil {
+//			// ...
+//		}
+//		// ...
+//	}
+func (s *OrderService) Seq(ctx context.Context, params *OrderQueryParams) iter.Seq2[Order, error] {
+	return func(yield func(Order, error) bool) {
+		it := s.Iter(ctx, params)
+		for it.Next() {
+			if !yield(it.Order(), nil) {
+				return
+			}
+		}
+		if err := it.Err(); err != nil {
+			yield(Order{}, err)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+//go:build go1.23
+
+package sepay
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestOrderService_Seq(t *testing.T) {
+	t.Run("yields all orders", func(t *testing.T) {
+		var requests int
+		c, closeFn := newPagedServer(t, 2, &requests)
+		defer closeFn()
+
+		var got []string
+		for order, err := range c.Order.Seq(context.Background(), &OrderQueryParams{OrderStatus: OrderStatusCaptured}) {
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got = append(got, order.OrderInvoiceNumber)
+		}
+		if len(got) != 4 {
+			t.Errorf("expected 4 orders, got %v", got)
+		}
+	})
+
+	t.Run("break stops fetching", func(t *testing.T) {
+		var requests int
+		c, closeFn := newPagedServer(t, 3, &requests)
+		defer closeFn()
+
+		for range c.Order.Seq(context.Background(), &OrderQueryParams{OrderStatus: OrderStatusCaptured}) {
+			break
+		}
+		if requests != 1 {
+			t.Errorf("expected 1 request, got %d", requests)
+		}
+	})
+
+	t.Run("yields error", func(t *testing.T) {
+		c, ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(502)
+		})
+		defer ts.Close()
+
+		var errs int
+		for _, err := range c.Order.Seq(context.Background(), nil) {
+			if err != nil {
+				errs++
+			}
+		}
+		if errs != 1 {
+			t.Errorf("expected 1 error, got %d", errs)
+		}
+	})
+}
@@ -0,0 +1,127 @@
+package sepay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+// newPagedServer serves lastPage pages of two orders each and counts the
+// requests it receives.
+func newPagedServer(t *testing.T, lastPage int, requests *int) (*Client, func()) {
+	t.Helper()
+	c, ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if r.URL.Query().Get("order_status") != "CAPTURED" {
+			t.Errorf("expected query params to be forwarded, got %q", r.URL.RawQuery)
+		}
+		w.WriteHeader(200)
+		fmt.Fprintf(w, `{"data":[{"order_invoice_number":"INV-%d-1"},{"order_invoice_number":"INV-%d-2"}],`+
+			`"meta":{"total":%d,"per_page":2,"current_page":%d,"last_page":%d}}`,
+			page, page, lastPage*2, page, lastPage)
+	})
+	return c, ts.Close
+}
+
+func TestOrderIterator(t *testing.T) {
+	t.Run("walks all pages", func(t *testing.T) {
+		var requests int
+		c, closeFn := newPagedServer(t, 3, &requests)
+		defer closeFn()
+
+		it := c.Order.Iter(context.Background(), &OrderQueryParams{OrderStatus: OrderStatusCaptured})
+		var got []string
+		for it.Next() {
+			got = append(got, it.Order().OrderInvoiceNumber)
+		}
+		if err := it.Err(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) != 6 {
+			t.Fatalf("expected 6 orders, got %d: %v", len(got), got)
+		}
+		if got[0] != "INV-1-1" || got[5] != "INV-3-2" {
+			t.Errorf("unexpected order sequence: %v", got)
+		}
+		if requests != 3 {
+			t.Errorf("expected 3 requests, got %d", requests)
+		}
+		if it.Next() {
+			t.Error("expected exhausted iterator to stay exhausted")
+		}
+	})
+
+	t.Run("starts at requested page", func(t *testing.T) {
+		var requests int
+		c, closeFn := newPagedServer(t, 3, &requests)
+		defer closeFn()
+
+		it := c.Order.Iter(context.Background(), &OrderQueryParams{
+			Page:        Int(3),
+			OrderStatus: OrderStatusCaptured,
+		})
+		var n int
+		for it.Next() {
+			n++
+		}
+		if n != 2 || requests != 1 {
+			t.Errorf("expected 2 orders in 1 request, got %d in %d", n, requests)
+		}
+	})
+
+	t.Run("stops on cancelled context", func(t *testing.T) {
+		var requests int
+		c, closeFn := newPagedServer(t, 3, &requests)
+		defer closeFn()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		it := c.Order.Iter(ctx, &OrderQueryParams{OrderStatus: OrderStatusCaptured})
+		for it.Next() {
+			cancel()
+		}
+		if !errors.Is(it.Err(), context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", it.Err())
+		}
+		if requests != 1 {
+			t.Errorf("expected 1 request, got %d", requests)
+		}
+	})
+
+	t.Run("reports api error", func(t *testing.T) {
+		c, ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(500)
+		})
+		defer ts.Close()
+
+		it := c.Order.Iter(context.Background(), nil)
+		if it.Next() {
+			t.Fatal("expected no orders")
+		}
+		var apiErr *APIError
+		if !errors.As(it.Err(), &apiErr) {
+			t.Fatalf("expected *APIError, got %v", it.Err())
+		}
+	})
+
+	t.Run("stops when server does not advance", func(t *testing.T) {
+		var requests int
+		c, ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.WriteHeader(200)
+			w.Write([]byte(`{"data":[{"order_invoice_number":"INV-1"}],"meta":{"current_page":1,"last_page":5}}`))
+		})
+		defer ts.Close()
+
+		it := c.Order.Iter(context.Background(), &OrderQueryParams{Page: Int(2)})
+		for it.Next() {
+		}
+		if requests != 1 {
+			t.Errorf("expected 1 request, got %d", requests)
+		}
+	})
+}
@@ -4,11 +4,15 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
+// newTestServer returns a client of a test server running handler. Retries
+// are disabled; tests of retrying enable them on c.config.Retry.
 func newTestServer(t *testing.T, handler http.HandlerFunc) (*Client, *httptest.Server) {
 	t.Helper()
 	ts := httptest.NewServer(handler)
@@ -16,6 +20,7 @@ func newTestServer(t *testing.T, handler http.HandlerFunc) (*Client, *httptest.S
 		Env:        Sandbox,
 		MerchantID: "merchant123",
 		SecretKey:  "secret456",
+		Retry:      NoRetries,
 	})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -74,8 +79,8 @@ func TestOrderService_All(t *testing.T) {
 
 		_, err := c.Order.All(context.Background(), &OrderQueryParams{
 			PerPage:       Int(10),
-			OrderStatus:   String("COMPLETED"),
-			SortCreatedAt: String("desc"),
+			OrderStatus:   OrderStatusCompleted,
+			SortCreatedAt: SortDesc,
 		})
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
@@ -83,6 +88,149 @@ func TestOrderService_All(t *testing.T) {
 	})
 }
 
+func TestOrderQueryParams_toValues(t *testing.T) {
+	// 2024-01-31 20:30:00 UTC is 2024-02-01 03:30:00 in the SePay timezone.
+	from := time.Date(2024, 1, 31, 20, 30, 0, 0, time.UTC)
+	to := time.Date(2024, 2, 29, 23, 59, 59, 0, sepayLocation)
+
+	v := (&OrderQueryParams{
+		CreatedAt:     from,
+		FromCreatedAt: from,
+		ToCreatedAt:   to,
+	}).toValues()
+
+	expected := map[string]string{
+		"created_at":      "2024-02-01",
+		"from_created_at": "2024-02-01",
+		"to_created_at":   "2024-02-29",
+	}
+	for k, want := range expected {
+		if got := v.Get(k); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+	if v.Has("order_status") || v.Has("sort[created_at]") {
+		t.Errorf("expected zero-valued typed fields to be omitted, got %v", v)
+	}
+}
+
+func TestOrderQueryParams_Validate(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name   string
+		params *OrderQueryParams
+		field  string
+	}{
+		{"nil params", nil, ""},
+		{"valid params", &OrderQueryParams{
+			PerPage:       Int(10),
+			OrderStatus:   OrderStatusCancelled,
+			FromCreatedAt: now.Add(-time.Hour),
+			ToCreatedAt:   now,
+			SortCreatedAt: SortAsc,
+		}, ""},
+		{"zero page", &OrderQueryParams{Page: Int(0)}, "Page"},
+		{"negative per page", &OrderQueryParams{PerPage: Int(-1)}, "PerPage"},
+		{"unknown status", &OrderQueryParams{OrderStatus: "complete"}, "OrderStatus"},
+		{"unknown sort direction", &OrderQueryParams{SortCreatedAt: "descending"}, "SortCreatedAt"},
+		{"inverted range", &OrderQueryParams{FromCreatedAt: now, ToCreatedAt: now.Add(-48 * time.Hour)}, "FromCreatedAt"},
+		{"single day range", &OrderQueryParams{
+			FromCreatedAt: time.Date(2024, 2, 1, 12, 0, 0, 0, sepayLocation),
+			ToCreatedAt:   time.Date(2024, 2, 1, 9, 0, 0, 0, sepayLocation),
+		}, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.params.Validate()
+			if tc.field == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			valErr, ok := err.(*ValidationError)
+			if !ok {
+				t.Fatalf("expected *ValidationError, got %T", err)
+			}
+			if valErr.Field != tc.field {
+				t.Errorf("expected field %q, got %q", tc.field, valErr.Field)
+			}
+		})
+	}
+
+	t.Run("All rejects invalid params without a request", func(t *testing.T) {
+		c, ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+			t.Error("unexpected request")
+		})
+		defer ts.Close()
+
+		resp, err := c.Order.All(context.Background(), &OrderQueryParams{OrderStatus: "complete"})
+		if _, ok := err.(*ValidationError); !ok {
+			t.Fatalf("expected *ValidationError, got %T", err)
+		}
+		if resp != nil {
+			t.Error("expected nil response")
+		}
+	})
+}
+
+func TestOrderService_ListOrders(t *testing.T) {
+	t.Run("decodes page and pagination", func(t *testing.T) {
+		c, ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+			q := r.URL.Query()
+			if q.Get("page") != "2" {
+				t.Errorf("expected page=2, got %q", q.Get("page"))
+			}
+			if q.Get("per_page") != "2" {
+				t.Errorf("expected per_page=2, got %q", q.Get("per_page"))
+			}
+			w.WriteHeader(200)
+			w.Write([]byte(`{
+				"data": [
+					{"order_invoice_number": "INV-003", "order_amount": "30000.00"},
+					{"order_invoice_number": "INV-004", "order_amount": "40000.00"}
+				],
+				"meta": {"total": 5, "per_page": 2, "current_page": 2, "last_page": 3}
+			}`))
+		})
+		defer ts.Close()
+
+		list, _, err := c.Order.ListOrders(context.Background(), &OrderQueryParams{
+			Page:    Int(2),
+			PerPage: Int(2),
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(list.Orders) != 2 {
+			t.Fatalf("expected 2 orders, got %d", len(list.Orders))
+		}
+		if list.Orders[1].OrderInvoiceNumber != "INV-004" {
+			t.Errorf("expected INV-004, got %q", list.Orders[1].OrderInvoiceNumber)
+		}
+		want := Pagination{Total: 5, PerPage: 2, CurrentPage: 2, LastPage: 3, NextPage: 3}
+		if list.Pagination != want {
+			t.Errorf("expected pagination %+v, got %+v", want, list.Pagination)
+		}
+	})
+
+	t.Run("last page has no next page", func(t *testing.T) {
+		c, ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(200)
+			w.Write([]byte(`{"data":[],"meta":{"total":5,"per_page":2,"current_page":3,"last_page":3}}`))
+		})
+		defer ts.Close()
+
+		list, _, err := c.Order.ListOrders(context.Background(), &OrderQueryParams{Page: Int(3)})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if list.Pagination.NextPage != 0 {
+			t.Errorf("expected no next page, got %d", list.Pagination.NextPage)
+		}
+	})
+}
+
 func TestOrderService_Retrieve(t *testing.T) {
 	c, ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "GET" {
@@ -105,6 +253,156 @@ func TestOrderService_Retrieve(t *testing.T) {
 	}
 }
 
+func TestOrderService_RetrieveOrder(t *testing.T) {
+	t.Run("decodes order", func(t *testing.T) {
+		c, ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/order/detail/INV-001" {
+				t.Errorf("expected path /order/detail/INV-001, got %s", r.URL.Path)
+			}
+			w.Header().Set("X-Request-Id", "req-1")
+			w.WriteHeader(200)
+			w.Write([]byte(`{"data":{
+				"id": 42,
+				"order_id": "ord_abc",
+				"order_invoice_number": "INV-001",
+				"order_status": "CAPTURED",
+				"order_amount": "50000.00",
+				"order_currency": "VND",
+				"order_description": "Test order",
+				"customer_id": "CUST-001",
+				"custom_data": "extra",
+				"created_at": "2024-03-01 10:15:00",
+				"updated_at": "2024-03-01 10:16:30",
+				"transactions": [{
+					"id": "tx_1",
+					"transaction_id": "T0001",
+					"payment_method": "BANK_TRANSFER",
+					"transaction_type": "PAYMENT",
+					"transaction_date": "2024-03-01 10:16:00",
+					"transaction_status": "APPROVED",
+					"transaction_amount": 50000,
+					"transaction_currency": "VND"
+				}]
+			}}`))
+		})
+		defer ts.Close()
+
+		order, resp, err := c.Order.RetrieveOrder(context.Background(), "INV-001")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Header.Get("X-Request-Id") != "req-1" {
+			t.Errorf("expected response header to be exposed")
+		}
+		if order.ID != "42" {
+			t.Errorf("expected ID %q, got %q", "42", order.ID)
+		}
+		if order.OrderInvoiceNumber != "INV-001" {
+			t.Errorf("expected invoice number %q, got %q", "INV-001", order.OrderInvoiceNumber)
+		}
+		if order.OrderStatus != OrderStatusCaptured {
+			t.Errorf("expected status %q, got %q", OrderStatusCaptured, order.OrderStatus)
+		}
+		if order.OrderAmount != VND(50000) {
+			t.Errorf("expected amount 50000 VND, got %v", order.OrderAmount)
+		}
+		if order.CustomData != "extra" {
+			t.Errorf("expected custom data %q, got %q", "extra", order.CustomData)
+		}
+		wantCreated := time.Date(2024, 3, 1, 3, 15, 0, 0, time.UTC)
+		if !order.CreatedAt.Equal(wantCreated) {
+			t.Errorf("expected created_at %v, got %v", wantCreated, order.CreatedAt)
+		}
+		if len(order.Transactions) != 1 {
+			t.Fatalf("expected 1 transaction, got %d", len(order.Transactions))
+		}
+		tx := order.Transactions[0]
+		if tx.PaymentMethod != BankTransfer {
+			t.Errorf("expected payment method %q, got %q", BankTransfer, tx.PaymentMethod)
+		}
+		if tx.TransactionAmount != VND(50000) {
+			t.Errorf("expected transaction amount 50000 VND, got %v", tx.TransactionAmount)
+		}
+	})
+
+	t.Run("decodes saved card agreement", func(t *testing.T) {
+		c, ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(200)
+			w.Write([]byte(`{"data":{
+				"order_invoice_number": "VER-001",
+				"order_amount": "0.00",
+				"order_currency": "USD",
+				"agreement": {
+					"agreement_id": "AGR-001",
+					"agreement_type": "UNSCHEDULED",
+					"agreement_status": "ACTIVE",
+					"agreement_amount_per_payment": "9.90",
+					"card_token": "tok_abc",
+					"card_number": "411111xxxxxx1111",
+					"card_brand": "VISA"
+				}
+			}}`))
+		})
+		defer ts.Close()
+
+		order, _, err := c.Order.RetrieveOrder(context.Background(), "VER-001")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		a := order.Agreement
+		if a == nil {
+			t.Fatal("expected agreement")
+		}
+		if a.CardToken != "tok_abc" {
+			t.Errorf("expected card token %q, got %q", "tok_abc", a.CardToken)
+		}
+		if a.AgreementType != AgreementTypeUnscheduled {
+			t.Errorf("expected agreement type %q, got %q", AgreementTypeUnscheduled, a.AgreementType)
+		}
+		if a.AmountPerPayment != NewAmount(990, "USD") {
+			t.Errorf("expected amount per payment 9.90 USD, got %v", a.AmountPerPayment)
+		}
+	})
+
+	t.Run("empty custom data array", func(t *testing.T) {
+		c, ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(200)
+			w.Write([]byte(`{"data":{"order_invoice_number":"INV-001","custom_data":[]}}`))
+		})
+		defer ts.Close()
+
+		order, _, err := c.Order.RetrieveOrder(context.Background(), "INV-001")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if order.CustomData != "" {
+			t.Errorf("expected empty custom data, got %q", order.CustomData)
+		}
+		if order.Agreement != nil {
+			t.Errorf("expected no agreement, got %+v", order.Agreement)
+		}
+	})
+
+	t.Run("api error", func(t *testing.T) {
+		c, ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(404)
+			w.Write([]byte(`{"message":"not found"}`))
+		})
+		defer ts.Close()
+
+		order, resp, err := c.Order.RetrieveOrder(context.Background(), "INV-404")
+		if err == nil {
+			t.Fatal("expected error for 404 response")
+		}
+		if order != nil {
+			t.Errorf("expected nil order, got %+v", order)
+		}
+		if resp == nil || resp.StatusCode != 404 {
+			t.Errorf("expected response with status 404")
+		}
+	})
+}
+
 func TestOrderService_VoidTransaction(t *testing.T) {
 	c, ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "POST" {
@@ -178,6 +476,9 @@ func TestOrderService_APIError(t *testing.T) {
 	if apiErr.StatusCode != 401 {
 		t.Errorf("expected status 401, got %d", apiErr.StatusCode)
 	}
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("expected error to match ErrUnauthorized")
+	}
 
 	// Response should still be available.
 	if resp == nil {
@@ -0,0 +1,209 @@
+package sepay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// initialThrottle is the first pause after a 429 response without a
+	// Retry-After header. It doubles with every further 429.
+	initialThrottle = time.Second
+	// defaultMaxThrottle caps the pause after 429 responses.
+	defaultMaxThrottle = 30 * time.Second
+)
+
+// errRateLimitDeadline is returned when waiting for the rate limiter would
+// outlast the context deadline.
+var errRateLimitDeadline = errors.New("rate limit wait would exceed context deadline")
+
+// Limit is a token bucket: requests are allowed at Rate per second on
+// average, with bursts of up to Burst requests.
+type Limit struct {
+	// Rate is the number of requests per second.
+	Rate float64
+	// Burst is the number of requests that may be made at once. Defaults
+	// to Rate rounded up, and at least 1.
+	Burst int
+}
+
+// RateLimit configures a RateLimiter.
+type RateLimit struct {
+	// Limit applies to all requests together. A zero Rate means no global
+	// limit.
+	Limit
+	// Operations limits individual operations on top of the global limit,
+	// keyed by "Order.All", "Order.Retrieve", "Order.Cancel" or
+	// "Order.VoidTransaction". ListOrders, the order iterators and
+	// RetrieveOrder count as All and Retrieve.
+	Operations map[string]Limit
+	// MaxThrottle caps the pause after SePay responds 429 Too Many Requests
+	// without a Retry-After header. Defaults to 30 seconds.
+	MaxThrottle time.Duration
+}
+
+// RateLimiter limits the rate of outbound API requests. It is safe for
+// concurrent use and may be shared by several clients, e.g. every client of
+// a ClientRegistry.
+//
+// When SePay responds 429 Too Many Requests, the limiter holds back all
+// requests for the time given in the Retry-After header or, without one,
+// for a pause that doubles with every consecutive 429 up to MaxThrottle.
+type RateLimiter struct {
+	maxThrottle time.Duration
+	now         func() time.Time
+	sleep       func(ctx context.Context, d time.Duration) error
+
+	mu          sync.Mutex
+	global      *tokenBucket
+	operations  map[string]*tokenBucket
+	pausedUntil time.Time
+	penalty     time.Duration
+}
+
+// NewRateLimiter returns a RateLimiter enforcing the given limits.
+func NewRateLimiter(limit RateLimit) *RateLimiter {
+	l := &RateLimiter{
+		maxThrottle: limit.MaxThrottle,
+		now:         time.Now,
+		sleep:       sleepContext,
+		global:      newTokenBucket(limit.Limit),
+		operations:  make(map[string]*tokenBucket),
+	}
+	if l.maxThrottle <= 0 {
+		l.maxThrottle = defaultMaxThrottle
+	}
+	for op, opLimit := range limit.Operations {
+		if b := newTokenBucket(opLimit); b != nil {
+			l.operations[op] = b
+		}
+	}
+	return l
+}
+
+// Wait blocks until a request for the given operation is allowed or ctx is
+// done. It fails immediately if the wait would outlast the ctx deadline.
+func (l *RateLimiter) Wait(ctx context.Context, operation string) error {
+	_, err := l.wait(ctx, operation)
+	return err
+}
+
+// wait is Wait, also returning how long the request waited before it was
+// allowed. It returns zero if the request was not allowed.
+func (l *RateLimiter) wait(ctx context.Context, operation string) (time.Duration, error) {
+	l.mu.Lock()
+	now := l.now()
+	var delay time.Duration
+	var reserved []*tokenBucket
+	for _, b := range []*tokenBucket{l.global, l.operations[operation]} {
+		if b == nil {
+			continue
+		}
+		if d := b.reserve(now); d > delay {
+			delay = d
+		}
+		reserved = append(reserved, b)
+	}
+	if d := l.pausedUntil.Sub(now); d > delay {
+		delay = d
+	}
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return 0, nil
+	}
+	err := ctx.Err()
+	if deadline, ok := ctx.Deadline(); ok && err == nil && deadline.Sub(now) < delay {
+		err = errRateLimitDeadline
+	}
+	if err == nil {
+		err = l.sleep(ctx, delay)
+	}
+	if err != nil {
+		l.mu.Lock()
+		for _, b := range reserved {
+			b.cancel()
+		}
+		l.mu.Unlock()
+		return 0, fmt.Errorf("sepay: waiting for rate limit: %w", err)
+	}
+	return delay, nil
+}
+
+// throttle holds back all requests after a 429 response, for retryAfter if
+// positive or else for the adaptive penalty.
+func (l *RateLimiter) throttle(retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	pause := retryAfter
+	if pause <= 0 {
+		if l.penalty == 0 {
+			l.penalty = initialThrottle
+		} else if l.penalty *= 2; l.penalty > l.maxThrottle {
+			l.penalty = l.maxThrottle
+		}
+		pause = l.penalty
+	}
+	if until := l.now().Add(pause); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// recover resets the adaptive penalty after a request that was not
+// throttled.
+func (l *RateLimiter) recover() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.penalty = 0
+}
+
+// tokenBucket is the state of a Limit. Its methods must be called with the
+// RateLimiter's mutex held.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket returns a full bucket for limit, or nil if it has no rate.
+func newTokenBucket(limit Limit) *tokenBucket {
+	if limit.Rate <= 0 {
+		return nil
+	}
+	burst := float64(limit.Burst)
+	if burst <= 0 {
+		burst = float64(int(limit.Rate))
+		if burst < limit.Rate {
+			burst++
+		}
+	}
+	return &tokenBucket{rate: limit.Rate, burst: burst, tokens: burst}
+}
+
+// reserve takes a token and returns how long to wait until it is available.
+// The token count may go negative, queueing later requests behind it.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel returns a reserved token that was not used.
+func (b *tokenBucket) cancel() {
+	if b.tokens++; b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
@@ -0,0 +1,137 @@
+package sepay
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// newTestRateLimiter returns a limiter on a fake clock that advances by the
+// time slept, and the list of sleeps.
+func newTestRateLimiter(limit RateLimit) (*RateLimiter, *time.Time, *[]time.Duration) {
+	l := NewRateLimiter(limit)
+	// Start at the real time so that context deadlines are comparable.
+	now := time.Now()
+	var sleeps []time.Duration
+	l.now = func() time.Time { return now }
+	l.sleep = func(ctx context.Context, d time.Duration) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		sleeps = append(sleeps, d)
+		now = now.Add(d)
+		return nil
+	}
+	return l, &now, &sleeps
+}
+
+func TestRateLimiter_Wait(t *testing.T) {
+	l, now, sleeps := newTestRateLimiter(RateLimit{
+		Limit:      Limit{Rate: 10, Burst: 2},
+		Operations: map[string]Limit{"Order.All": {Rate: 1}},
+	})
+	ctx := context.Background()
+
+	// The global burst allows two requests at once, then one per 100ms.
+	for i := 0; i < 3; i++ {
+		if err := l.Wait(ctx, "Order.Retrieve"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if len(*sleeps) != 1 || (*sleeps)[0] != 100*time.Millisecond {
+		t.Errorf("expected one 100ms wait, got %v", *sleeps)
+	}
+
+	// Order.All is limited to one per second on top of the global limit.
+	*now = now.Add(time.Second)
+	*sleeps = nil
+	l.Wait(ctx, "Order.All")
+	l.Wait(ctx, "Order.All")
+	if len(*sleeps) != 1 || (*sleeps)[0] != time.Second {
+		t.Errorf("expected one 1s wait, got %v", *sleeps)
+	}
+}
+
+func TestRateLimiter_Deadline(t *testing.T) {
+	l, now, sleeps := newTestRateLimiter(RateLimit{Limit: Limit{Rate: 1}})
+	l.Wait(context.Background(), "Order.All")
+
+	ctx, cancel := context.WithDeadline(context.Background(), now.Add(500*time.Millisecond))
+	defer cancel()
+	if err := l.Wait(ctx, "Order.All"); !errors.Is(err, errRateLimitDeadline) {
+		t.Errorf("expected deadline error, got %v", err)
+	}
+	if len(*sleeps) != 0 {
+		t.Errorf("expected no wait, got %v", *sleeps)
+	}
+
+	// The failed wait gave its token back.
+	l.Wait(context.Background(), "Order.All")
+	if len(*sleeps) != 1 || (*sleeps)[0] != time.Second {
+		t.Errorf("expected one 1s wait, got %v", *sleeps)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Wait(cancelled, "Order.All"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestRateLimiter_Throttle(t *testing.T) {
+	l, _, sleeps := newTestRateLimiter(RateLimit{MaxThrottle: 3 * time.Second})
+	ctx := context.Background()
+
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second} {
+		*sleeps = nil
+		l.throttle(0)
+		l.Wait(ctx, "Order.All")
+		if len(*sleeps) != 1 || (*sleeps)[0] != want {
+			t.Errorf("expected a %v pause, got %v", want, *sleeps)
+		}
+	}
+
+	l.recover()
+	*sleeps = nil
+	l.throttle(0)
+	l.Wait(ctx, "Order.All")
+	if len(*sleeps) != 1 || (*sleeps)[0] != time.Second {
+		t.Errorf("expected the pause to reset after recover, got %v", *sleeps)
+	}
+
+	*sleeps = nil
+	l.throttle(5 * time.Second)
+	l.Wait(ctx, "Order.All")
+	if len(*sleeps) != 1 || (*sleeps)[0] != 5*time.Second {
+		t.Errorf("expected the Retry-After pause, got %v", *sleeps)
+	}
+}
+
+func TestClient_RateLimiter(t *testing.T) {
+	var requests int
+	c, ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(429)
+			return
+		}
+		w.Write([]byte(`{"data":{"order_invoice_number":"INV-001"}}`))
+	})
+	defer ts.Close()
+	l, _, sleeps := newTestRateLimiter(RateLimit{})
+	c.config.RateLimiter = l
+	c.config.Retry = NoRetries
+
+	if _, err := c.Order.Retrieve(context.Background(), "INV-001"); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+	if _, err := c.Order.Retrieve(context.Background(), "INV-001"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(*sleeps) != 1 || (*sleeps)[0] != 7*time.Second {
+		t.Errorf("expected the next request to wait 7s, got %v", *sleeps)
+	}
+}
@@ -16,3 +16,19 @@ type Response struct {
 func (r *Response) DecodeJSON(v any) error {
 	return json.Unmarshal(r.Body, v)
 }
+
+// decodeData decodes the payload of the response into v. SePay wraps
+// payloads in a {"data": ...} envelope; bodies without the envelope are
+// decoded as-is.
+func (r *Response) decodeData(v any) error {
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := r.DecodeJSON(&envelope); err != nil {
+		return err
+	}
+	if len(envelope.Data) == 0 {
+		return r.DecodeJSON(v)
+	}
+	return json.Unmarshal(envelope.Data, v)
+}
@@ -0,0 +1,137 @@
+package sepay
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy configures how failed API requests are retried. Requests are
+// retried on network errors and on 429, 502, 503 and 504 responses, but only
+// if they are safe to repeat: GET requests, and POST requests made with
+// RetrySafe.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts per request, including
+	// the first. Values below 2 disable retries.
+	MaxAttempts int
+	// InitialBackoff is the base delay before the first retry. It doubles
+	// with every further retry. Each delay is randomized between half and
+	// all of its value.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts. A Retry-After header
+	// asking for a longer delay ends the retries.
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy is used when Config.Retry is nil.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 250 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+}
+
+// NoRetries disables retries when set as Config.Retry.
+var NoRetries = &RetryPolicy{MaxAttempts: 1}
+
+// RequestOption configures a single API call.
+type RequestOption func(*apiRequest)
+
+// RetrySafe marks a state-changing request as safe to repeat, so that it is
+// retried according to the client's RetryPolicy like a GET request. Only use
+// it when repeating the request cannot cause a duplicate effect.
+func RetrySafe() RequestOption {
+	return func(r *apiRequest) {
+		r.retrySafe = true
+	}
+}
+
+// retryableStatus reports whether a response status is worth retrying.
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryDelay returns how long to wait before attempt+1 of r, which failed
+// with resp and err, and whether to retry at all.
+func (p *RetryPolicy) retryDelay(ctx context.Context, r *apiRequest, attempt int, resp *Response, err error) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts || !r.retrySafe || ctx.Err() != nil {
+		return 0, false
+	}
+
+	switch {
+	case resp != nil:
+		if !retryableStatus(resp.StatusCode) {
+			return 0, false
+		}
+	default:
+		// Only transport failures are retried, not e.g. failures to
+		// resolve the secret key.
+		var urlErr *url.Error
+		if !errors.As(err, &urlErr) {
+			return 0, false
+		}
+	}
+
+	backoff := p.InitialBackoff << (attempt - 1)
+	if backoff <= 0 || (p.MaxBackoff > 0 && backoff > p.MaxBackoff) {
+		backoff = p.MaxBackoff
+	}
+	if backoff > 0 {
+		backoff = backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+	}
+
+	if resp != nil {
+		if after, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			if p.MaxBackoff > 0 && after > p.MaxBackoff {
+				return 0, false
+			}
+			if after > backoff {
+				backoff = after
+			}
+		}
+	}
+	return backoff, true
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an
+// HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// sleepContext waits for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
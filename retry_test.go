@@ -0,0 +1,233 @@
+package sepay
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newRetryTestServer returns a client whose requests get the given statuses
+// in turn, recording request bodies and the delays slept between attempts.
+func newRetryTestServer(t *testing.T, statuses []int, header http.Header) (*Client, *[]string, *[]time.Duration, func()) {
+	t.Helper()
+	var mu sync.Mutex
+	var bodies []string
+	c, ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		status := statuses[len(bodies)-1]
+		mu.Unlock()
+		if status == -1 {
+			// Drop the connection to cause a network error.
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		for k, v := range header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(`{"data":{"order_invoice_number":"INV-001"}}`))
+	})
+	// A dropped connection gives the race detector no happens-before edge
+	// between the handler and the caller, so take mu after every attempt.
+	c.Use(func(next RequestHandler) RequestHandler {
+		return func(req *http.Request, info RequestInfo) (*http.Response, error) {
+			resp, err := next(req, info)
+			mu.Lock()
+			mu.Unlock()
+			return resp, err
+		}
+	})
+	policy := DefaultRetryPolicy
+	c.config.Retry = &policy
+	var delays []time.Duration
+	c.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return ctx.Err()
+	}
+	return c, &bodies, &delays, ts.Close
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		call     func(c *Client) error
+		attempts int
+		wantErr  bool
+	}{
+		{"GET retried until success", []int{503, 502, 200}, retrieveINV001, 3, false},
+		{"GET gives up after MaxAttempts", []int{504, 429, 503}, retrieveINV001, 3, true},
+		{"GET retried on network error", []int{-1, 200}, retrieveINV001, 2, false},
+		{"GET not retried on 500", []int{500}, retrieveINV001, 1, true},
+		{"GET not retried on 404", []int{404}, retrieveINV001, 1, true},
+		{"POST not retried", []int{503}, func(c *Client) error {
+			_, err := c.Order.Cancel(context.Background(), "INV-001")
+			return err
+		}, 1, true},
+		{"POST not retried on network error", []int{-1}, func(c *Client) error {
+			_, err := c.Order.Cancel(context.Background(), "INV-001")
+			return err
+		}, 1, true},
+		{"retry-safe POST retried", []int{503, 502, 200}, func(c *Client) error {
+			_, err := c.Order.Cancel(context.Background(), "INV-001", RetrySafe())
+			return err
+		}, 3, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c, bodies, delays, closeFn := newRetryTestServer(t, tc.statuses, nil)
+			defer closeFn()
+
+			err := tc.call(c)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error %v, got %v", tc.wantErr, err)
+			}
+			if len(*bodies) != tc.attempts {
+				t.Errorf("expected %d attempts, got %d", tc.attempts, len(*bodies))
+			}
+			if len(*delays) != tc.attempts-1 {
+				t.Errorf("expected %d delays, got %d", tc.attempts-1, len(*delays))
+			}
+			for _, body := range *bodies {
+				if body != (*bodies)[0] {
+					t.Errorf("expected replayed body %q, got %q", (*bodies)[0], body)
+				}
+			}
+		})
+	}
+}
+
+// net/http treats requests carrying an Idempotency-Key header as replayable:
+// when a reused connection fails before the request was answered, the
+// transport re-sends it by itself, whether or not the client would retry it.
+func TestRetry_TransportResendsKeyedPOST(t *testing.T) {
+	t.Run("retry-safe", func(t *testing.T) {
+		c, bodies, delays, closeFn := newRetryTestServer(t, []int{503, -1, 200}, nil)
+		defer closeFn()
+
+		if _, err := c.Order.Cancel(context.Background(), "INV-001", RetrySafe()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(*bodies) != 3 || len(*delays) != 1 {
+			t.Errorf("expected 2 client attempts and 3 requests, got %d delays and %d requests", len(*delays), len(*bodies))
+		}
+	})
+
+	t.Run("not retry-safe", func(t *testing.T) {
+		c, bodies, delays, closeFn := newRetryTestServer(t, []int{200, -1, 200}, nil)
+		defer closeFn()
+
+		// Leave an idle connection for the Cancel to reuse.
+		if err := retrieveINV001(c); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := c.Order.Cancel(context.Background(), "INV-001"); err != nil {
+			t.Fatalf("expected the transport to re-send the request, got %v", err)
+		}
+		if len(*bodies) != 3 || len(*delays) != 0 {
+			t.Errorf("expected 3 requests without client retries, got %d delays and %d requests", len(*delays), len(*bodies))
+		}
+	})
+}
+
+func retrieveINV001(c *Client) error {
+	_, err := c.Order.Retrieve(context.Background(), "INV-001")
+	return err
+}
+
+func TestRetry_Backoff(t *testing.T) {
+	c, _, delays, closeFn := newRetryTestServer(t, []int{503, 503, 503, 503, 200}, nil)
+	defer closeFn()
+	c.config.Retry = &RetryPolicy{MaxAttempts: 5, InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}
+
+	if err := retrieveINV001(c); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	limits := []time.Duration{100, 200, 300, 300}
+	for i, d := range *delays {
+		max := limits[i] * time.Millisecond
+		if d < max/2 || d > max {
+			t.Errorf("delay %d: expected between %v and %v, got %v", i, max/2, max, d)
+		}
+	}
+}
+
+func TestRetry_RetryAfter(t *testing.T) {
+	c, bodies, delays, closeFn := newRetryTestServer(t, []int{429, 200}, http.Header{"Retry-After": {"2"}})
+	defer closeFn()
+	if err := retrieveINV001(c); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(*delays) != 1 || (*delays)[0] != 2*time.Second {
+		t.Errorf("expected a 2s delay, got %v", *delays)
+	}
+
+	c, bodies, _, closeFn = newRetryTestServer(t, []int{429, 200}, http.Header{"Retry-After": {"60"}})
+	defer closeFn()
+	if err := retrieveINV001(c); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expected ErrRateLimited, got %v", err)
+	}
+	if len(*bodies) != 1 {
+		t.Errorf("expected no retry beyond MaxBackoff, got %d attempts", len(*bodies))
+	}
+}
+
+func TestRetry_Disabled(t *testing.T) {
+	c, bodies, _, closeFn := newRetryTestServer(t, []int{503, 200}, nil)
+	defer closeFn()
+	c.config.Retry = NoRetries
+	if err := retrieveINV001(c); err == nil {
+		t.Error("expected error")
+	}
+	if len(*bodies) != 1 {
+		t.Errorf("expected 1 attempt, got %d", len(*bodies))
+	}
+}
+
+func TestRetry_ContextCancelled(t *testing.T) {
+	c, bodies, _, closeFn := newRetryTestServer(t, []int{503, 200}, nil)
+	defer closeFn()
+	ctx, cancel := context.WithCancel(context.Background())
+	c.sleep = func(context.Context, time.Duration) error {
+		cancel()
+		return ctx.Err()
+	}
+
+	_, err := c.Order.Retrieve(ctx, "INV-001")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 503 {
+		t.Errorf("expected the last attempt's error, got %v", err)
+	}
+	if len(*bodies) != 1 {
+		t.Errorf("expected 1 attempt, got %d", len(*bodies))
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Second, true},
+		{"-1", 0, false},
+		{"Fri, 01 Mar 2024 10:00:05 GMT", 5 * time.Second, true},
+		{"Fri, 01 Mar 2024 09:00:00 GMT", 0, true},
+		{"soon", 0, false},
+	}
+	for _, tc := range tests {
+		got, ok := parseRetryAfter(tc.value, now)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %v; expected %v, %v", tc.value, got, ok, tc.want, tc.ok)
+		}
+	}
+}
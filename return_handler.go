@@ -0,0 +1,116 @@
+package sepay
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ReturnFunc handles a customer returning from the hosted checkout page, with
+// the order as confirmed by the SePay API.
+type ReturnFunc func(w http.ResponseWriter, r *http.Request, order *Order)
+
+// ReturnHandler is an http.Handler for the SuccessURL, ErrorURL and CancelURL
+// the customer is redirected to after checkout. Query parameters are never
+// trusted: the handler only takes the invoice number from them, retrieves the
+// order through OrderService.RetrieveOrder, and dispatches on its actual
+// status. The same handler can therefore serve all three URLs.
+type ReturnHandler struct {
+	Order *OrderService
+
+	// OnSuccess is called for captured or completed orders.
+	OnSuccess ReturnFunc
+	// OnCancel is called for cancelled or voided orders.
+	OnCancel ReturnFunc
+	// OnPending is called for orders that are not settled yet. Defaults to
+	// OnError.
+	OnPending ReturnFunc
+	// OnError is called for orders in any other status.
+	OnError ReturnFunc
+
+	// InvoiceNumber extracts the invoice number from the request. Defaults
+	// to the "order_invoice_number" query value.
+	InvoiceNumber func(r *http.Request) string
+	// ErrorHandler, if set, writes the response when the order cannot be
+	// confirmed. The error is a *HandlerError. Defaults to a plain-text
+	// status response.
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+	// orders, if set, resolves the OrderService for the request instead of
+	// Order, e.g. to pick the merchant's client from a ClientRegistry.
+	orders func(r *http.Request) (*OrderService, error)
+}
+
+// NewReturnHandler returns a ReturnHandler that confirms orders through the
+// given OrderService. Set the On* callbacks before serving requests.
+func NewReturnHandler(orders *OrderService) *ReturnHandler {
+	return &ReturnHandler{Order: orders}
+}
+
+func (h *ReturnHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		h.fail(w, r, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	invoiceNumber := h.invoiceNumber(r)
+	// The invoice number ends up in the request path, so reject anything
+	// that is not a well-formed invoice number before using it.
+	var errs ValidationErrors
+	validateInvoiceNumber(&errs, invoiceNumber)
+	if err := errs.err(); err != nil {
+		h.fail(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	orders := h.Order
+	if h.orders != nil {
+		var err error
+		if orders, err = h.orders(r); err != nil {
+			h.fail(w, r, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	order, _, err := orders.RetrieveOrder(r.Context(), invoiceNumber)
+	if err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, ErrOrderNotFound) {
+			status = http.StatusNotFound
+		}
+		h.fail(w, r, status, err)
+		return
+	}
+
+	if fn := h.callback(order.OrderStatus); fn != nil {
+		fn(w, r, order)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// callback returns the callback for the given order status.
+func (h *ReturnHandler) callback(status OrderStatus) ReturnFunc {
+	switch status {
+	case OrderStatusCaptured, OrderStatusCompleted:
+		return h.OnSuccess
+	case OrderStatusCancelled, OrderStatusVoided:
+		return h.OnCancel
+	case OrderStatusPending:
+		if h.OnPending != nil {
+			return h.OnPending
+		}
+	}
+	return h.OnError
+}
+
+func (h *ReturnHandler) invoiceNumber(r *http.Request) string {
+	if h.InvoiceNumber != nil {
+		return h.InvoiceNumber(r)
+	}
+	return r.URL.Query().Get("order_invoice_number")
+}
+
+func (h *ReturnHandler) fail(w http.ResponseWriter, r *http.Request, status int, err error) {
+	handleError(w, r, h.ErrorHandler, &HandlerError{StatusCode: status, Err: err})
+}
@@ -0,0 +1,118 @@
+package sepay
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestReturnHandler(t *testing.T, requests *int) (*ReturnHandler, func()) {
+	t.Helper()
+	c, ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		invoice := strings.TrimPrefix(r.URL.Path, "/order/detail/")
+		statuses := map[string]string{
+			"INV-PAID":      "CAPTURED",
+			"INV-COMPLETED": "COMPLETED",
+			"INV-CANCELLED": "CANCELLED",
+			"INV-VOIDED":    "VOIDED",
+			"INV-PENDING":   "PENDING",
+			"INV-OTHER":     "AUTHENTICATION_NOT_NEEDED",
+		}
+		status, ok := statuses[invoice]
+		if !ok {
+			w.WriteHeader(404)
+			return
+		}
+		w.WriteHeader(200)
+		fmt.Fprintf(w, `{"data":{"order_invoice_number":%q,"order_status":%q}}`, invoice, status)
+	})
+
+	h := NewReturnHandler(c.Order)
+	respond := func(name string) ReturnFunc {
+		return func(w http.ResponseWriter, r *http.Request, order *Order) {
+			fmt.Fprintf(w, "%s:%s", name, order.OrderInvoiceNumber)
+		}
+	}
+	h.OnSuccess = respond("success")
+	h.OnCancel = respond("cancel")
+	h.OnError = respond("error")
+	return h, ts.Close
+}
+
+func TestReturnHandler(t *testing.T) {
+	tests := []struct {
+		target string
+		body   string
+	}{
+		{"/return/success?order_invoice_number=INV-PAID", "success:INV-PAID"},
+		{"/return/success?order_invoice_number=INV-COMPLETED", "success:INV-COMPLETED"},
+		{"/return/cancel?order_invoice_number=INV-CANCELLED", "cancel:INV-CANCELLED"},
+		{"/return/cancel?order_invoice_number=INV-VOIDED", "cancel:INV-VOIDED"},
+		{"/return/error?order_invoice_number=INV-OTHER", "error:INV-OTHER"},
+		// The success URL is not trusted: the confirmed status wins.
+		{"/return/success?order_invoice_number=INV-PENDING", "error:INV-PENDING"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.target, func(t *testing.T) {
+			var requests int
+			h, closeFn := newTestReturnHandler(t, &requests)
+			defer closeFn()
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest("GET", tc.target, nil))
+			if rec.Body.String() != tc.body {
+				t.Errorf("expected body %q, got %q", tc.body, rec.Body.String())
+			}
+			if requests != 1 {
+				t.Errorf("expected order to be retrieved once, got %d", requests)
+			}
+		})
+	}
+
+	t.Run("pending callback", func(t *testing.T) {
+		var requests int
+		h, closeFn := newTestReturnHandler(t, &requests)
+		defer closeFn()
+		h.OnPending = func(w http.ResponseWriter, r *http.Request, order *Order) {
+			w.Write([]byte("pending"))
+		}
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/return?order_invoice_number=INV-PENDING", nil))
+		if rec.Body.String() != "pending" {
+			t.Errorf("expected pending callback, got %q", rec.Body.String())
+		}
+	})
+
+	errorTests := []struct {
+		name     string
+		method   string
+		target   string
+		status   int
+		requests int
+	}{
+		{"missing invoice", "GET", "/return", 400, 0},
+		{"path traversal", "GET", "/return?order_invoice_number=..%2F..%2Forder%2Fcancel", 400, 0},
+		{"unknown order", "GET", "/return?order_invoice_number=INV-404", 404, 1},
+		{"method not allowed", "POST", "/return?order_invoice_number=INV-PAID", 405, 0},
+	}
+	for _, tc := range errorTests {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests int
+			h, closeFn := newTestReturnHandler(t, &requests)
+			defer closeFn()
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.target, nil))
+			if rec.Code != tc.status {
+				t.Errorf("expected status %d, got %d", tc.status, rec.Code)
+			}
+			if requests != tc.requests {
+				t.Errorf("expected %d API requests, got %d", tc.requests, requests)
+			}
+		})
+	}
+}
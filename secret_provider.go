@@ -0,0 +1,138 @@
+package sepay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SecretProvider supplies the merchant secret key whenever the client needs
+// it, so that the key does not have to be held in Config for the lifetime of
+// the process.
+type SecretProvider interface {
+	// SecretKey returns the current secret key.
+	SecretKey(ctx context.Context) (string, error)
+}
+
+// secretKey returns the active secret key from the configured provider.
+func (c *Config) secretKey(ctx context.Context) (string, error) {
+	secret, err := c.SecretProvider.SecretKey(ctx)
+	if err != nil {
+		return "", fmt.Errorf("sepay: resolving secret key: %w", err)
+	}
+	if secret == "" {
+		return "", errors.New("sepay: resolving secret key: empty key")
+	}
+	return secret, nil
+}
+
+// StaticSecret is a SecretProvider that always returns the same key.
+type StaticSecret string
+
+// SecretKey returns s.
+func (s StaticSecret) SecretKey(context.Context) (string, error) {
+	return string(s), nil
+}
+
+// EnvSecret is a SecretProvider that reads the key from the environment
+// variable it names on every call.
+type EnvSecret string
+
+// SecretKey returns the value of the environment variable e.
+func (e EnvSecret) SecretKey(context.Context) (string, error) {
+	secret := os.Getenv(string(e))
+	if secret == "" {
+		return "", fmt.Errorf("sepay: environment variable %s is not set", string(e))
+	}
+	return secret, nil
+}
+
+// FileSecret is a SecretProvider that reads the key from a file, e.g. a
+// mounted Kubernetes secret. The file is read again whenever its
+// modification time or size changes. Surrounding whitespace is trimmed.
+type FileSecret struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	secret  string
+}
+
+// NewFileSecret returns a FileSecret reading the key from path.
+func NewFileSecret(path string) *FileSecret {
+	return &FileSecret{path: path}
+}
+
+// SecretKey returns the key in the file, reading it again if the file has
+// changed since the last call.
+func (f *FileSecret) SecretKey(context.Context) (string, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return "", fmt.Errorf("sepay: reading secret file: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.secret != "" && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.secret, nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return "", fmt.Errorf("sepay: reading secret file: %w", err)
+	}
+	secret := strings.TrimSpace(string(data))
+	if secret == "" {
+		return "", fmt.Errorf("sepay: secret file %s is empty", f.path)
+	}
+	f.secret, f.modTime, f.size = secret, info.ModTime(), info.Size()
+	return secret, nil
+}
+
+// CachedSecret is a SecretProvider that caches the key of another provider
+// for a fixed time, e.g. to limit calls to a remote secret manager. Errors
+// are not cached.
+type CachedSecret struct {
+	provider SecretProvider
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu        sync.Mutex
+	secret    string
+	fetchedAt time.Time
+}
+
+// NewCachedSecret returns a CachedSecret that calls provider at most once per
+// ttl.
+func NewCachedSecret(provider SecretProvider, ttl time.Duration) *CachedSecret {
+	return &CachedSecret{provider: provider, ttl: ttl, now: time.Now}
+}
+
+// SecretKey returns the cached key, fetching it from the underlying provider
+// if it is older than the TTL.
+func (c *CachedSecret) SecretKey(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.secret != "" && c.now().Sub(c.fetchedAt) < c.ttl {
+		return c.secret, nil
+	}
+
+	secret, err := c.provider.SecretKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	c.secret, c.fetchedAt = secret, c.now()
+	return secret, nil
+}
+
+// Invalidate drops the cached key, so the next call fetches it again.
+func (c *CachedSecret) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.secret = ""
+}
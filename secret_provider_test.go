@@ -0,0 +1,170 @@
+package sepay
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countingSecret is a SecretProvider returning a fixed key or error and
+// counting its calls.
+type countingSecret struct {
+	secret string
+	err    error
+	calls  int
+}
+
+func (c *countingSecret) SecretKey(context.Context) (string, error) {
+	c.calls++
+	return c.secret, c.err
+}
+
+func TestStaticSecret(t *testing.T) {
+	secret, err := StaticSecret("secret456").SecretKey(context.Background())
+	if err != nil || secret != "secret456" {
+		t.Errorf("expected secret456, got %q, %v", secret, err)
+	}
+}
+
+func TestEnvSecret(t *testing.T) {
+	t.Setenv("SEPAY_TEST_SECRET", "from-env")
+	secret, err := EnvSecret("SEPAY_TEST_SECRET").SecretKey(context.Background())
+	if err != nil || secret != "from-env" {
+		t.Errorf("expected from-env, got %q, %v", secret, err)
+	}
+
+	t.Setenv("SEPAY_TEST_SECRET", "")
+	if _, err := EnvSecret("SEPAY_TEST_SECRET").SecretKey(context.Background()); err == nil {
+		t.Error("expected error for empty variable")
+	}
+}
+
+func TestFileSecret(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret")
+	write := func(content string, modTime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mtime := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	write("first-secret\n", mtime)
+	f := NewFileSecret(path)
+	if secret, err := f.SecretKey(context.Background()); err != nil || secret != "first-secret" {
+		t.Fatalf("expected first-secret, got %q, %v", secret, err)
+	}
+
+	write("second-secret\n", mtime.Add(time.Minute))
+	if secret, err := f.SecretKey(context.Background()); err != nil || secret != "second-secret" {
+		t.Errorf("expected the changed file to be read again, got %q, %v", secret, err)
+	}
+
+	write("\n", mtime.Add(2*time.Minute))
+	if _, err := f.SecretKey(context.Background()); err == nil {
+		t.Error("expected error for empty file")
+	}
+
+	if _, err := NewFileSecret(filepath.Join(t.TempDir(), "missing")).SecretKey(context.Background()); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestCachedSecret(t *testing.T) {
+	provider := &countingSecret{secret: "secret456"}
+	c := NewCachedSecret(provider, time.Minute)
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if secret, err := c.SecretKey(context.Background()); err != nil || secret != "secret456" {
+			t.Fatalf("expected secret456, got %q, %v", secret, err)
+		}
+	}
+	if provider.calls != 1 {
+		t.Errorf("expected 1 call within the TTL, got %d", provider.calls)
+	}
+
+	now = now.Add(time.Minute)
+	c.SecretKey(context.Background())
+	if provider.calls != 2 {
+		t.Errorf("expected a call after the TTL, got %d", provider.calls)
+	}
+
+	c.Invalidate()
+	c.SecretKey(context.Background())
+	if provider.calls != 3 {
+		t.Errorf("expected a call after Invalidate, got %d", provider.calls)
+	}
+
+	failing := &countingSecret{err: errors.New("vault unavailable")}
+	c = NewCachedSecret(failing, time.Minute)
+	c.SecretKey(context.Background())
+	if _, err := c.SecretKey(context.Background()); err == nil || failing.calls != 2 {
+		t.Errorf("expected errors not to be cached, got %v after %d calls", err, failing.calls)
+	}
+}
+
+func TestClient_SecretProvider(t *testing.T) {
+	var cfgErr *ConfigError
+	_, err := NewClient(Config{Env: Sandbox, MerchantID: "merchant123", SecretKey: "secret456", SecretProvider: StaticSecret("other")})
+	if !errors.As(err, &cfgErr) || cfgErr.Field != "SecretProvider" {
+		t.Errorf("expected SecretProvider *ConfigError, got %v", err)
+	}
+
+	provider := &countingSecret{secret: "from-provider"}
+	c, ts := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		if user != "merchant123" || pass != "from-provider" {
+			w.WriteHeader(401)
+			return
+		}
+		w.Write([]byte(`{"data":{"order_invoice_number":"INV-001"}}`))
+	})
+	defer ts.Close()
+	c.config.SecretKey = ""
+	c.config.SecretProvider = provider
+
+	if _, _, err := c.Order.RetrieveOrder(context.Background(), "INV-001"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fields := OnetimePaymentFields{
+		OrderInvoiceNumber: "INV-001",
+		OrderAmount:        VND(100000),
+		OrderDescription:   "Test payment",
+	}
+	signed, err := c.Checkout.SignOneTimePayment(fields)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if signed.Signature != signFields(signed.FormValues(), "from-provider") {
+		t.Error("expected fields to be signed with the provider's key")
+	}
+
+	provider.err = errors.New("vault unavailable")
+	if _, _, err := c.Order.RetrieveOrder(context.Background(), "INV-001"); !errors.Is(err, provider.err) {
+		t.Errorf("expected provider error, got %v", err)
+	}
+	if _, err := c.Checkout.SignOneTimePayment(fields); !errors.Is(err, provider.err) {
+		t.Errorf("expected provider error, got %v", err)
+	}
+	var logs bytes.Buffer
+	c.config.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+	if signed := c.Checkout.InitOneTimePaymentFields(fields); signed.Signature != "" {
+		t.Errorf("expected empty signature, got %q", signed.Signature)
+	}
+	if !strings.Contains(logs.String(), "level=ERROR") || !strings.Contains(logs.String(), "vault unavailable") {
+		t.Errorf("expected the signing error to be logged, got %q", logs.String())
+	}
+}
@@ -1,6 +1,11 @@
 package sepay
 
-import "net/http"
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
 
 // Environment represents the SePay environment.
 type Environment string
@@ -26,11 +31,42 @@ const (
 
 // Config holds the configuration for a SePay client.
 type Config struct {
-	Env             Environment
-	MerchantID      string
-	SecretKey       string
+	Env        Environment
+	MerchantID string
+	// SecretKey is the active secret key, used for signing checkout fields
+	// and authenticating API requests. Set either SecretKey or
+	// SecretProvider.
+	SecretKey string
+	// SecretProvider supplies the active secret key on demand instead of
+	// SecretKey, e.g. from an environment variable or a mounted file.
+	SecretProvider SecretProvider
+	// SecretKeyID identifies SecretKey in verification results. Defaults
+	// to "active".
+	SecretKeyID string
+	// PreviousKeys are still accepted when verifying inbound signatures and
+	// webhook secrets, so that a key can be rotated without rejecting
+	// notifications and forms signed with the old key. They are never used
+	// for signing.
+	PreviousKeys    []VerificationKey
 	APIVersion      APIVersion
 	CheckoutVersion CheckoutVersion
+	// Retry configures retries of failed API requests. Defaults to
+	// DefaultRetryPolicy; set NoRetries to disable them.
+	Retry *RetryPolicy
+	// RateLimiter, if set, limits the rate of API requests. It may be
+	// shared by several clients.
+	RateLimiter *RateLimiter
+	// CircuitBreaker, if set, fails requests fast with ErrCircuitOpen
+	// during SePay outages. It may be shared by several clients.
+	CircuitBreaker *CircuitBreaker
+	// Logger, if set, receives a record for every attempt at an API
+	// request. Secrets, signatures and the Authorization header are never
+	// logged.
+	Logger *slog.Logger
+	// LogLevel is the level of records for successful requests. Failed
+	// requests are logged at least at slog.LevelWarn. Defaults to
+	// slog.LevelInfo.
+	LogLevel slog.Level
 }
 
 // Client is the SePay payment gateway client.
@@ -42,6 +78,11 @@ type Client struct {
 	baseAPIURL      string
 	baseCheckoutURL string
 	httpClient      *http.Client
+	now             func() time.Time
+	sleep           func(ctx context.Context, d time.Duration) error
+	mutations       *mutationLog
+	middleware      []Middleware
+	hooks           []Hooks
 }
 
 // NewClient creates a new SePay client with the given configuration.
@@ -49,8 +90,25 @@ func NewClient(cfg Config) (*Client, error) {
 	if cfg.MerchantID == "" {
 		return nil, &ConfigError{Field: "MerchantID", Message: "must not be empty"}
 	}
-	if cfg.SecretKey == "" {
+	switch {
+	case cfg.SecretKey == "" && cfg.SecretProvider == nil:
 		return nil, &ConfigError{Field: "SecretKey", Message: "must not be empty"}
+	case cfg.SecretKey != "" && cfg.SecretProvider != nil:
+		return nil, &ConfigError{Field: "SecretProvider", Message: "must not be set together with SecretKey"}
+	case cfg.SecretProvider == nil:
+		cfg.SecretProvider = StaticSecret(cfg.SecretKey)
+	}
+
+	if cfg.SecretKeyID == "" {
+		cfg.SecretKeyID = defaultSecretKeyID
+	}
+	if err := validateKeys(&cfg); err != nil {
+		return nil, err
+	}
+
+	if cfg.Retry == nil {
+		policy := DefaultRetryPolicy
+		cfg.Retry = &policy
 	}
 
 	if cfg.APIVersion == "" {
@@ -81,6 +139,13 @@ func NewClient(cfg Config) (*Client, error) {
 		baseAPIURL:      baseAPIURL,
 		baseCheckoutURL: baseCheckoutURL,
 		httpClient:      &http.Client{},
+		now:             time.Now,
+		sleep:           sleepContext,
+		mutations:       newMutationLog(),
+	}
+
+	if cfg.Logger != nil {
+		c.Use(loggingMiddleware(cfg.Logger, cfg.LogLevel))
 	}
 
 	c.Order = &OrderService{api: apiResource{client: c}}
@@ -0,0 +1,268 @@
+// Package sepayotel instruments the SePay client with OpenTelemetry tracing.
+//
+// Instrument records a span per API operation, with HTTP and SePay
+// attributes, propagates the trace context on outbound requests, and records
+// spans for checkout signing and webhook handling:
+//
+//	client, err := sepay.NewClient(cfg)
+//	sepayotel.Instrument(client)
+//	wh := client.NewWebhookHandler() // traced too
+package sepayotel
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	sepay "github.com/emizuki/sepay-go-sdk"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope name of the tracer.
+const ScopeName = "github.com/emizuki/sepay-go-sdk/sepayotel"
+
+// SePay-specific span attributes.
+const (
+	MerchantIDKey       = attribute.Key("sepay.merchant_id")
+	InvoiceNumberKey    = attribute.Key("sepay.invoice_number")
+	OrderStatusKey      = attribute.Key("sepay.order_status")
+	ErrorCodeKey        = attribute.Key("sepay.error_code")
+	AttemptKey          = attribute.Key("sepay.attempt")
+	AttemptsKey         = attribute.Key("sepay.attempts")
+	RetryDelayKey       = attribute.Key("sepay.retry.delay_ms")
+	RateLimitDelayKey   = attribute.Key("sepay.rate_limit.delay_ms")
+	CheckoutOpKey       = attribute.Key("sepay.checkout.operation")
+	PaymentMethodKey    = attribute.Key("sepay.payment_method")
+	CurrencyKey         = attribute.Key("sepay.currency")
+	WebhookEventKey     = attribute.Key("sepay.webhook.event_type")
+	WebhookOutcomeKey   = attribute.Key("sepay.webhook.outcome")
+	WebhookDuplicateKey = attribute.Key("sepay.webhook.duplicate")
+	WebhookKeyIDKey     = attribute.Key("sepay.webhook.key_id")
+)
+
+// operationSpanKey is the context key of the span started by the
+// OperationStart hook, which the middleware annotates.
+type operationSpanKey struct{}
+
+// config holds the instrumentation options.
+type config struct {
+	tracerProvider trace.TracerProvider
+	propagators    propagation.TextMapPropagator
+}
+
+// Option configures the instrumentation.
+type Option func(*config)
+
+// WithTracerProvider sets the TracerProvider. Defaults to the global one.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = tp
+	}
+}
+
+// WithPropagators sets the propagators used to inject the trace context
+// into outbound requests. Defaults to the global ones.
+func WithPropagators(p propagation.TextMapPropagator) Option {
+	return func(c *config) {
+		c.propagators = p
+	}
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{
+		tracerProvider: otel.GetTracerProvider(),
+		propagators:    otel.GetTextMapPropagator(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Instrument adds tracing to the client. Webhook handlers created by the
+// client afterwards are traced as well. Instrument must be called before the
+// client is used concurrently.
+func Instrument(c *sepay.Client, opts ...Option) {
+	cfg := newConfig(opts)
+	c.AddHooks(Hooks(opts...))
+	c.Use(cfg.middleware())
+}
+
+// InstrumentWebhookHandler adds tracing to a webhook handler that was not
+// created by an instrumented client.
+func InstrumentWebhookHandler(h *sepay.WebhookHandler, opts ...Option) {
+	h.AddHooks(Hooks(opts...))
+}
+
+// Hooks returns the hooks that record spans, for use with Client.AddHooks
+// and WebhookHandler.AddHooks. Propagating the trace context and recording
+// HTTP attributes also requires Middleware.
+func Hooks(opts ...Option) sepay.Hooks {
+	cfg := newConfig(opts)
+	tracer := cfg.tracerProvider.Tracer(ScopeName)
+	return sepay.Hooks{
+		OperationStart: func(ctx context.Context, info sepay.RequestInfo) context.Context {
+			attrs := []attribute.KeyValue{}
+			if info.InvoiceNumber != "" {
+				attrs = append(attrs, InvoiceNumberKey.String(info.InvoiceNumber))
+			}
+			ctx, span := tracer.Start(ctx, "sepay "+info.Operation(),
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(attrs...),
+			)
+			return context.WithValue(ctx, operationSpanKey{}, span)
+		},
+		OperationDone: func(ctx context.Context, info sepay.RequestInfo, result sepay.OperationResult) {
+			span := trace.SpanFromContext(ctx)
+			defer span.End()
+			span.SetAttributes(AttemptsKey.Int(result.Attempts))
+			if result.OrderStatus != "" {
+				span.SetAttributes(OrderStatusKey.String(string(result.OrderStatus)))
+			}
+			if result.Err == nil {
+				return
+			}
+			span.RecordError(result.Err)
+			span.SetStatus(codes.Error, result.Err.Error())
+			errorType := "_OTHER"
+			var apiErr *sepay.APIError
+			if errors.As(result.Err, &apiErr) {
+				errorType = strconv.Itoa(apiErr.StatusCode)
+				if apiErr.Code != "" {
+					span.SetAttributes(ErrorCodeKey.String(apiErr.Code))
+				}
+			} else if errors.Is(result.Err, sepay.ErrCircuitOpen) {
+				errorType = "circuit_open"
+			}
+			span.SetAttributes(semconv.ErrorTypeKey.String(errorType))
+		},
+		Retry: func(ctx context.Context, info sepay.RequestInfo, delay time.Duration, err error) {
+			trace.SpanFromContext(ctx).AddEvent("sepay.retry", trace.WithAttributes(
+				AttemptKey.Int(info.Attempt),
+				RetryDelayKey.Int64(delay.Milliseconds()),
+				attribute.String("exception.message", err.Error()),
+			))
+		},
+		RateLimitWait: func(ctx context.Context, info sepay.RequestInfo, delay time.Duration) {
+			trace.SpanFromContext(ctx).AddEvent("sepay.rate_limit_wait", trace.WithAttributes(
+				AttemptKey.Int(info.Attempt),
+				RateLimitDelayKey.Int64(delay.Milliseconds()),
+			))
+		},
+		CheckoutSigned: func(ctx context.Context, fields *sepay.SignedCheckoutFields, err error) {
+			_, span := tracer.Start(ctx, "sepay checkout.sign", trace.WithAttributes(
+				MerchantIDKey.String(fields.Merchant),
+				InvoiceNumberKey.String(fields.OrderInvoiceNumber),
+				CheckoutOpKey.String(string(fields.Operation)),
+				PaymentMethodKey.String(string(fields.PaymentMethod)),
+				CurrencyKey.String(fields.Currency),
+			))
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		},
+		WebhookStart: func(ctx context.Context, r *http.Request) context.Context {
+			ctx, _ = tracer.Start(ctx, "sepay webhook",
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(semconv.HTTPRequestMethodKey.String(r.Method)),
+			)
+			return ctx
+		},
+		WebhookDone: func(ctx context.Context, result sepay.WebhookResult) {
+			span := trace.SpanFromContext(ctx)
+			defer span.End()
+			span.SetAttributes(
+				WebhookOutcomeKey.String(string(result.Outcome)),
+				semconv.HTTPResponseStatusCode(result.StatusCode),
+			)
+			if e := result.Event; e != nil {
+				span.SetAttributes(
+					WebhookEventKey.String(string(e.Type)),
+					WebhookDuplicateKey.Bool(e.Duplicate),
+					WebhookKeyIDKey.String(e.KeyID),
+				)
+				if e.Order != nil && e.Order.OrderInvoiceNumber != "" {
+					span.SetAttributes(InvoiceNumberKey.String(e.Order.OrderInvoiceNumber))
+				}
+				if e.Order != nil && e.Order.OrderStatus != "" {
+					span.SetAttributes(OrderStatusKey.String(string(e.Order.OrderStatus)))
+				}
+			}
+			if result.Err != nil {
+				span.RecordError(result.Err)
+			}
+			if result.StatusCode >= 500 {
+				span.SetStatus(codes.Error, string(result.Outcome))
+			}
+		},
+	}
+}
+
+// Middleware returns the request middleware that injects the trace context
+// into outbound requests and records HTTP attributes of every attempt, for
+// use with Client.Use. The attributes go on the operation span started by
+// Hooks; without Hooks, each attempt gets a client span of its own.
+func Middleware(opts ...Option) sepay.Middleware {
+	return newConfig(opts).middleware()
+}
+
+func (cfg *config) middleware() sepay.Middleware {
+	tracer := cfg.tracerProvider.Tracer(ScopeName)
+	return func(next sepay.RequestHandler) sepay.RequestHandler {
+		return func(req *http.Request, info sepay.RequestInfo) (*http.Response, error) {
+			span, ok := req.Context().Value(operationSpanKey{}).(trace.Span)
+			if !ok {
+				var ctx context.Context
+				ctx, span = tracer.Start(req.Context(), "sepay "+info.Operation(),
+					trace.WithSpanKind(trace.SpanKindClient),
+				)
+				defer span.End()
+				req = req.WithContext(ctx)
+			}
+			cfg.propagators.Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+
+			attrs := []attribute.KeyValue{
+				semconv.HTTPRequestMethodKey.String(req.Method),
+				semconv.URLFull(req.URL.String()),
+				semconv.ServerAddress(req.URL.Hostname()),
+			}
+			if port := req.URL.Port(); port != "" {
+				if p, err := strconv.Atoi(port); err == nil {
+					attrs = append(attrs, semconv.ServerPort(p))
+				}
+			} else if req.URL.Scheme == "https" {
+				attrs = append(attrs, semconv.ServerPort(443))
+			}
+			if info.Attempt > 1 {
+				attrs = append(attrs, semconv.HTTPRequestResendCount(info.Attempt-1))
+			}
+			span.SetAttributes(attrs...)
+
+			resp, err := next(req, info)
+			if resp != nil {
+				span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+			}
+			if !ok {
+				// The attempt span has no OperationDone hook to set its
+				// status.
+				if err != nil {
+					span.RecordError(err)
+					span.SetStatus(codes.Error, err.Error())
+				} else if resp.StatusCode >= 400 {
+					span.SetStatus(codes.Error, "")
+					span.SetAttributes(semconv.ErrorTypeKey.String(strconv.Itoa(resp.StatusCode)))
+				}
+			}
+			return resp, err
+		}
+	}
+}
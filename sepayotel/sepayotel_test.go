@@ -0,0 +1,275 @@
+package sepayotel_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	sepay "github.com/emizuki/sepay-go-sdk"
+	"github.com/emizuki/sepay-go-sdk/sepayotel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// rewriteTransport sends every request to the test server instead of SePay.
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (t rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func newTracedClient(t *testing.T, handler http.HandlerFunc) (*sepay.Client, *tracetest.SpanRecorder, trace.Tracer) {
+	t.Helper()
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+	target, _ := url.Parse(ts.URL)
+
+	c, err := sepay.NewClient(sepay.Config{
+		Env:        sepay.Sandbox,
+		MerchantID: "merchant123",
+		SecretKey:  "secret456",
+		Retry:      &sepay.RetryPolicy{MaxAttempts: 2},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c.SetHTTPClient(&http.Client{Transport: rewriteTransport{target}})
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	sepayotel.Instrument(c,
+		sepayotel.WithTracerProvider(tp),
+		sepayotel.WithPropagators(propagation.TraceContext{}),
+	)
+	return c, recorder, tp.Tracer("test")
+}
+
+func attrs(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	m := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+func TestInstrument_Operation(t *testing.T) {
+	var requests int
+	var traceparents []string
+	c, recorder, tracer := newTracedClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		traceparents = append(traceparents, r.Header.Get("Traceparent"))
+		if requests == 1 {
+			w.WriteHeader(503)
+			return
+		}
+		w.Write([]byte(`{"data":{"order_invoice_number":"INV-001","order_status":"CAPTURED"}}`))
+	})
+
+	ctx, parent := tracer.Start(context.Background(), "parent")
+	if _, _, err := c.Order.RetrieveOrder(ctx, "INV-001"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "sepay Order.Retrieve" || span.SpanKind() != trace.SpanKindClient {
+		t.Errorf("unexpected span %q of kind %v", span.Name(), span.SpanKind())
+	}
+	if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("expected the operation span to be a child of the caller's span")
+	}
+
+	got := attrs(span)
+	want := map[attribute.Key]attribute.Value{
+		"sepay.invoice_number":      attribute.StringValue("INV-001"),
+		"sepay.order_status":        attribute.StringValue("CAPTURED"),
+		"sepay.attempts":            attribute.IntValue(2),
+		"http.request.method":       attribute.StringValue("GET"),
+		"http.response.status_code": attribute.IntValue(200),
+		"http.request.resend_count": attribute.IntValue(1),
+		"server.address":            attribute.StringValue("pgapi-sandbox.sepay.vn"),
+		"server.port":               attribute.IntValue(443),
+		"url.full":                  attribute.StringValue("https://pgapi-sandbox.sepay.vn/v1/order/detail/INV-001"),
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("expected %s=%v, got %v", k, v.Emit(), got[k].Emit())
+		}
+	}
+
+	if events := span.Events(); len(events) != 1 || events[0].Name != "sepay.retry" {
+		t.Errorf("expected a retry event, got %v", events)
+	}
+
+	traceID := span.SpanContext().TraceID().String()
+	spanID := span.SpanContext().SpanID().String()
+	for _, tp := range traceparents {
+		if !strings.Contains(tp, traceID) || !strings.Contains(tp, spanID) {
+			t.Errorf("expected traceparent for span %s/%s, got %q", traceID, spanID, tp)
+		}
+	}
+}
+
+func TestInstrument_OperationError(t *testing.T) {
+	c, recorder, _ := newTracedClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"code":"ORDER_NOT_FOUND","message":"Order not found"}`))
+	})
+
+	c.Order.Retrieve(context.Background(), "INV-404")
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Status().Code != codes.Error {
+		t.Errorf("expected error status, got %v", span.Status())
+	}
+	got := attrs(span)
+	if got["sepay.error_code"].AsString() != "ORDER_NOT_FOUND" || got["error.type"].AsString() != "404" {
+		t.Errorf("unexpected error attributes: %v", span.Attributes())
+	}
+	if _, ok := got["sepay.order_status"]; ok {
+		t.Error("expected no order status on failure")
+	}
+}
+
+func TestInstrument_CheckoutAndWebhook(t *testing.T) {
+	c, recorder, tracer := newTracedClient(t, func(w http.ResponseWriter, r *http.Request) {})
+
+	ctx, parent := tracer.Start(context.Background(), "parent")
+	_, err := c.Checkout.SignOneTimePaymentContext(ctx, sepay.OnetimePaymentFields{
+		PaymentMethod:      sepay.BankTransfer,
+		OrderInvoiceNumber: "INV-001",
+		OrderAmount:        sepay.VND(100000),
+		OrderDescription:   "Test payment",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	parent.End()
+
+	wh := c.NewWebhookHandler()
+	wh.On(sepay.EventOrderPaid, func(ctx context.Context, event *sepay.WebhookEvent) error {
+		return nil
+	})
+	body := `{"notification_type":"ORDER_PAID","order":{"order_invoice_number":"INV-001","order_status":"CAPTURED"}}`
+	for _, secret := range []string{"secret456", "wrong"} {
+		req := httptest.NewRequest("POST", "/ipn", strings.NewReader(body))
+		req.Header.Set(sepay.WebhookSecretHeader, secret)
+		wh.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 4 {
+		t.Fatalf("expected 4 spans, got %d", len(spans))
+	}
+
+	sign := spans[0]
+	if sign.Name() != "sepay checkout.sign" || sign.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("unexpected signing span %q", sign.Name())
+	}
+	if got := attrs(sign); got["sepay.payment_method"].AsString() != "BANK_TRANSFER" || got["sepay.invoice_number"].AsString() != "INV-001" {
+		t.Errorf("unexpected signing attributes: %v", sign.Attributes())
+	}
+
+	for i, want := range []struct {
+		outcome string
+		status  int64
+	}{{"accepted", 200}, {"unauthorized", 401}} {
+		span := spans[2+i]
+		got := attrs(span)
+		if span.Name() != "sepay webhook" || span.SpanKind() != trace.SpanKindServer {
+			t.Errorf("unexpected webhook span %q", span.Name())
+		}
+		if got["sepay.webhook.outcome"].AsString() != want.outcome || got["http.response.status_code"].AsInt64() != want.status {
+			t.Errorf("unexpected webhook attributes: %v", span.Attributes())
+		}
+	}
+	if got := attrs(spans[2]); got["sepay.webhook.event_type"].AsString() != "ORDER_PAID" || got["sepay.invoice_number"].AsString() != "INV-001" {
+		t.Errorf("unexpected webhook event attributes: %v", spans[2].Attributes())
+	}
+}
+
+func TestMiddleware_WithoutHooks(t *testing.T) {
+	var traceparents []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents = append(traceparents, r.Header.Get("Traceparent"))
+		if len(traceparents) == 1 {
+			w.WriteHeader(503)
+			return
+		}
+		w.Write([]byte(`{"data":{"order_invoice_number":"INV-001","order_status":"CAPTURED"}}`))
+	}))
+	defer ts.Close()
+	target, _ := url.Parse(ts.URL)
+
+	c, err := sepay.NewClient(sepay.Config{
+		Env:        sepay.Sandbox,
+		MerchantID: "merchant123",
+		SecretKey:  "secret456",
+		Retry:      &sepay.RetryPolicy{MaxAttempts: 2},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c.SetHTTPClient(&http.Client{Transport: rewriteTransport{target}})
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	c.Use(sepayotel.Middleware(
+		sepayotel.WithTracerProvider(tp),
+		sepayotel.WithPropagators(propagation.TraceContext{}),
+	))
+
+	// The caller's span, e.g. of the inbound request, keeps its own
+	// attributes.
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "parent",
+		trace.WithAttributes(attribute.Int("http.response.status_code", 201)),
+	)
+	if _, _, err := c.Order.RetrieveOrder(ctx, "INV-001"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("expected 2 attempt spans and the parent, got %d spans", len(spans))
+	}
+	if got := attrs(spans[2]); len(got) != 1 || got["http.response.status_code"].AsInt64() != 201 {
+		t.Errorf("expected the parent span to be left alone, got %v", spans[2].Attributes())
+	}
+	for i, span := range spans[:2] {
+		if span.Name() != "sepay Order.Retrieve" || span.SpanKind() != trace.SpanKindClient {
+			t.Errorf("unexpected span %q of kind %v", span.Name(), span.SpanKind())
+		}
+		if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("expected attempt %d to be a child of the caller's span", i+1)
+		}
+		if !strings.Contains(traceparents[i], span.SpanContext().SpanID().String()) {
+			t.Errorf("expected traceparent of attempt %d, got %q", i+1, traceparents[i])
+		}
+	}
+	if got := attrs(spans[0]); got["http.response.status_code"].AsInt64() != 503 || spans[0].Status().Code != codes.Error {
+		t.Errorf("expected the first attempt to fail with 503, got %v %v", spans[0].Attributes(), spans[0].Status())
+	}
+	if got := attrs(spans[1]); got["http.response.status_code"].AsInt64() != 200 || got["http.request.resend_count"].AsInt64() != 1 {
+		t.Errorf("unexpected attributes of the second attempt: %v", spans[1].Attributes())
+	}
+}
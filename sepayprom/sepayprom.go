@@ -0,0 +1,201 @@
+// Package sepayprom exposes Prometheus metrics for the SePay client.
+//
+// A Collector counts API operations, retries, rate limiter waits, webhook
+// deliveries and signed checkout forms, using the client's hooks:
+//
+//	collector := sepayprom.NewCollector()
+//	collector.Instrument(client)
+//	prometheus.MustRegister(collector)
+//	wh := client.NewWebhookHandler() // counted too
+package sepayprom
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	sepay "github.com/emizuki/sepay-go-sdk"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Label values used when the SDK has nothing more specific.
+const (
+	// NoResponse is the code of operations that received no response,
+	// e.g. after a network error or when the circuit breaker is open.
+	NoResponse = "none"
+	// UnknownEvent is the event type of webhook requests that were rejected
+	// before the notification was decoded.
+	UnknownEvent = "unknown"
+	// UnspecifiedMethod is the payment method of checkout forms that let
+	// the customer choose one.
+	UnspecifiedMethod = "unspecified"
+)
+
+// config holds the collector options.
+type config struct {
+	namespace   string
+	constLabels prometheus.Labels
+	buckets     []float64
+}
+
+// Option configures a Collector.
+type Option func(*config)
+
+// WithNamespace sets the prefix of the metric names. Defaults to "sepay".
+func WithNamespace(namespace string) Option {
+	return func(c *config) {
+		c.namespace = namespace
+	}
+}
+
+// WithConstLabels sets labels added to every metric, e.g. to tell apart the
+// clients of several merchants.
+func WithConstLabels(labels prometheus.Labels) Option {
+	return func(c *config) {
+		c.constLabels = labels
+	}
+}
+
+// WithBuckets sets the buckets of the operation duration histogram, in
+// seconds. Defaults to prometheus.DefBuckets.
+func WithBuckets(buckets []float64) Option {
+	return func(c *config) {
+		c.buckets = buckets
+	}
+}
+
+// Collector is a prometheus.Collector of SePay client metrics. A Collector
+// may instrument several clients and webhook handlers.
+type Collector struct {
+	requests         *prometheus.CounterVec
+	requestDuration  *prometheus.HistogramVec
+	retries          *prometheus.CounterVec
+	rateLimitWaits   *prometheus.CounterVec
+	rateLimitSeconds *prometheus.CounterVec
+	webhooks         *prometheus.CounterVec
+	checkoutsSigned  *prometheus.CounterVec
+}
+
+// NewCollector returns a Collector with the given options.
+func NewCollector(opts ...Option) *Collector {
+	cfg := &config{
+		namespace: "sepay",
+		buckets:   prometheus.DefBuckets,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	counter := func(name, help string, labels ...string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   cfg.namespace,
+			Name:        name,
+			Help:        help,
+			ConstLabels: cfg.constLabels,
+		}, labels)
+	}
+	return &Collector{
+		requests: counter("requests_total",
+			"API operations by operation and HTTP status code of the last attempt.",
+			"operation", "code"),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   cfg.namespace,
+			Name:        "request_duration_seconds",
+			Help:        "Duration of API operations, including retries.",
+			ConstLabels: cfg.constLabels,
+			Buckets:     cfg.buckets,
+		}, []string{"operation", "code"}),
+		retries: counter("retries_total",
+			"Retried API request attempts by operation.",
+			"operation"),
+		rateLimitWaits: counter("rate_limit_waits_total",
+			"API requests that waited for the rate limiter, by operation.",
+			"operation"),
+		rateLimitSeconds: counter("rate_limit_wait_seconds_total",
+			"Time API requests spent waiting for the rate limiter, by operation.",
+			"operation"),
+		webhooks: counter("webhook_deliveries_total",
+			"Webhook requests by event type and outcome.",
+			"event_type", "outcome"),
+		checkoutsSigned: counter("checkout_forms_signed_total",
+			"Signed checkout forms by payment method.",
+			"payment_method"),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, m := range c.collectors() {
+		m.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range c.collectors() {
+		m.Collect(ch)
+	}
+}
+
+func (c *Collector) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		c.requests,
+		c.requestDuration,
+		c.retries,
+		c.rateLimitWaits,
+		c.rateLimitSeconds,
+		c.webhooks,
+		c.checkoutsSigned,
+	}
+}
+
+// Instrument adds the collector's hooks to the client. Webhook handlers
+// created by the client afterwards are counted as well. Instrument must be
+// called before the client is used concurrently.
+func (c *Collector) Instrument(client *sepay.Client) {
+	client.AddHooks(c.Hooks())
+}
+
+// InstrumentWebhookHandler adds the collector's hooks to a webhook handler
+// that was not created by an instrumented client.
+func (c *Collector) InstrumentWebhookHandler(h *sepay.WebhookHandler) {
+	h.AddHooks(c.Hooks())
+}
+
+// Hooks returns the hooks that record the collector's metrics, for use with
+// Client.AddHooks and WebhookHandler.AddHooks.
+func (c *Collector) Hooks() sepay.Hooks {
+	return sepay.Hooks{
+		OperationDone: func(ctx context.Context, info sepay.RequestInfo, result sepay.OperationResult) {
+			code := NoResponse
+			if result.Response != nil {
+				code = strconv.Itoa(result.Response.StatusCode)
+			}
+			c.requests.WithLabelValues(info.Operation(), code).Inc()
+			c.requestDuration.WithLabelValues(info.Operation(), code).Observe(result.Duration.Seconds())
+		},
+		Retry: func(ctx context.Context, info sepay.RequestInfo, delay time.Duration, err error) {
+			c.retries.WithLabelValues(info.Operation()).Inc()
+		},
+		RateLimitWait: func(ctx context.Context, info sepay.RequestInfo, delay time.Duration) {
+			c.rateLimitWaits.WithLabelValues(info.Operation()).Inc()
+			c.rateLimitSeconds.WithLabelValues(info.Operation()).Add(delay.Seconds())
+		},
+		CheckoutSigned: func(ctx context.Context, fields *sepay.SignedCheckoutFields, err error) {
+			if err != nil {
+				return
+			}
+			method := string(fields.PaymentMethod)
+			if method == "" {
+				method = UnspecifiedMethod
+			}
+			c.checkoutsSigned.WithLabelValues(method).Inc()
+		},
+		WebhookDone: func(ctx context.Context, result sepay.WebhookResult) {
+			event := UnknownEvent
+			if result.Event != nil && result.Event.Type != "" {
+				event = string(result.Event.Type)
+			}
+			c.webhooks.WithLabelValues(event, string(result.Outcome)).Inc()
+		},
+	}
+}
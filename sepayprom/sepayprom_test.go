@@ -0,0 +1,133 @@
+package sepayprom_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	sepay "github.com/emizuki/sepay-go-sdk"
+	"github.com/emizuki/sepay-go-sdk/sepayprom"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// rewriteTransport sends every request to the test server instead of SePay.
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (t rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestCollector(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch {
+		case requests == 1:
+			w.WriteHeader(503)
+		case strings.HasSuffix(r.URL.Path, "/INV-404"):
+			w.WriteHeader(404)
+			w.Write([]byte(`{"code":"ORDER_NOT_FOUND","message":"Order not found"}`))
+		default:
+			w.Write([]byte(`{"data":{"order_invoice_number":"INV-001","order_status":"CAPTURED"}}`))
+		}
+	}))
+	defer ts.Close()
+	target, _ := url.Parse(ts.URL)
+
+	c, err := sepay.NewClient(sepay.Config{
+		Env:         sepay.Sandbox,
+		MerchantID:  "merchant123",
+		SecretKey:   "secret456",
+		Retry:       &sepay.RetryPolicy{MaxAttempts: 2},
+		RateLimiter: sepay.NewRateLimiter(sepay.RateLimit{Limit: sepay.Limit{Rate: 100, Burst: 1}}),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c.SetHTTPClient(&http.Client{Transport: rewriteTransport{target}})
+
+	collector := sepayprom.NewCollector(sepayprom.WithConstLabels(prometheus.Labels{"merchant": "merchant123"}))
+	collector.Instrument(c)
+
+	ctx := context.Background()
+	if _, _, err := c.Order.RetrieveOrder(ctx, "INV-001"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c.Order.Retrieve(ctx, "INV-404")
+
+	c.Checkout.SignOneTimePayment(sepay.OnetimePaymentFields{
+		PaymentMethod:      sepay.BankTransfer,
+		OrderInvoiceNumber: "INV-001",
+		OrderAmount:        sepay.VND(100000),
+		OrderDescription:   "Test payment",
+	})
+	c.Checkout.SignOneTimePayment(sepay.OnetimePaymentFields{
+		OrderInvoiceNumber: "INV-002",
+		OrderAmount:        sepay.VND(100000),
+		OrderDescription:   "Test payment",
+	})
+
+	wh := c.NewWebhookHandler()
+	wh.On(sepay.EventOrderPaid, func(ctx context.Context, event *sepay.WebhookEvent) error {
+		return nil
+	})
+	body := `{"notification_type":"ORDER_PAID","order":{"order_invoice_number":"INV-001","order_status":"CAPTURED"}}`
+	for _, secret := range []string{"secret456", "secret456", "wrong"} {
+		req := httptest.NewRequest("POST", "/ipn", strings.NewReader(body))
+		req.Header.Set(sepay.WebhookSecretHeader, secret)
+		wh.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	expected := `
+# HELP sepay_requests_total API operations by operation and HTTP status code of the last attempt.
+# TYPE sepay_requests_total counter
+sepay_requests_total{code="200",merchant="merchant123",operation="Order.Retrieve"} 1
+sepay_requests_total{code="404",merchant="merchant123",operation="Order.Retrieve"} 1
+# HELP sepay_retries_total Retried API request attempts by operation.
+# TYPE sepay_retries_total counter
+sepay_retries_total{merchant="merchant123",operation="Order.Retrieve"} 1
+# HELP sepay_webhook_deliveries_total Webhook requests by event type and outcome.
+# TYPE sepay_webhook_deliveries_total counter
+sepay_webhook_deliveries_total{event_type="ORDER_PAID",merchant="merchant123",outcome="accepted"} 2
+sepay_webhook_deliveries_total{event_type="unknown",merchant="merchant123",outcome="unauthorized"} 1
+# HELP sepay_checkout_forms_signed_total Signed checkout forms by payment method.
+# TYPE sepay_checkout_forms_signed_total counter
+sepay_checkout_forms_signed_total{merchant="merchant123",payment_method="BANK_TRANSFER"} 1
+sepay_checkout_forms_signed_total{merchant="merchant123",payment_method="unspecified"} 1
+`
+	err = testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"sepay_requests_total",
+		"sepay_retries_total",
+		"sepay_webhook_deliveries_total",
+		"sepay_checkout_forms_signed_total",
+	)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if n := testutil.CollectAndCount(collector, "sepay_request_duration_seconds"); n != 2 {
+		t.Errorf("expected 2 duration histograms, got %d", n)
+	}
+	if n := testutil.CollectAndCount(collector, "sepay_rate_limit_waits_total"); n != 1 {
+		t.Errorf("expected rate limiter waits to be counted, got %d series", n)
+	}
+}
+
+func TestCollector_Lint(t *testing.T) {
+	problems, err := testutil.CollectAndLint(sepayprom.NewCollector())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, p := range problems {
+		t.Errorf("%s: %s", p.Metric, p.Text)
+	}
+}
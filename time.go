@@ -0,0 +1,37 @@
+package sepay
+
+import (
+	"fmt"
+	"time"
+)
+
+// sepayLocation is the timezone SePay uses for timestamps without an explicit
+// offset (Indochina Time, UTC+7).
+var sepayLocation = time.FixedZone("ICT", 7*60*60)
+
+// Layouts SePay uses for dates and timestamps in API payloads and queries.
+const (
+	dateLayout     = "2006-01-02"
+	dateTimeLayout = "2006-01-02 15:04:05"
+)
+
+// parseTime parses a timestamp returned by the SePay API. Both the SePay
+// layout (interpreted in ICT) and RFC 3339 are accepted. An empty string
+// yields the zero time.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation(dateTimeLayout, s, sepayLocation); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("sepay: invalid timestamp %q", s)
+}
+
+// formatDate formats t as a calendar date in the SePay timezone.
+func formatDate(t time.Time) string {
+	return t.In(sepayLocation).Format(dateLayout)
+}
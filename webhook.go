@@ -0,0 +1,363 @@
+package sepay
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// EventType identifies the kind of an instant payment notification (IPN).
+type EventType string
+
+const (
+	EventOrderPaid          EventType = "ORDER_PAID"
+	EventTransactionVoided  EventType = "TRANSACTION_VOID"
+	EventOrderCancelled     EventType = "ORDER_CANCELLED"
+	EventAgreementCreated   EventType = "AGREEMENT_CREATED"
+	EventAgreementCancelled EventType = "AGREEMENT_CANCELLED"
+)
+
+// WebhookSecretHeader is the request header carrying the merchant secret key
+// on instant payment notifications.
+const WebhookSecretHeader = "X-Secret-Key"
+
+// defaultMaxWebhookBodyBytes is the default limit on notification body size.
+const defaultMaxWebhookBodyBytes = 1 << 20
+
+// ErrInvalidWebhookSecret is returned when a notification does not carry the
+// merchant secret key.
+var ErrInvalidWebhookSecret = errors.New("sepay: invalid webhook secret")
+
+// WebhookEvent is a decoded instant payment notification.
+type WebhookEvent struct {
+	Type EventType
+	// Merchant is the merchant ID the notification is addressed to, if
+	// SePay includes it.
+	Merchant    string
+	Timestamp   time.Time
+	Order       *Order
+	Transaction *Transaction
+	Customer    *WebhookCustomer
+	Agreement   *Agreement
+	// Raw is the notification body as received.
+	Raw json.RawMessage
+	// Duplicate reports whether the WebhookHandler's DeliveryStore had
+	// already recorded this delivery, i.e. SePay sent it again.
+	Duplicate bool
+	// KeyID is the ID of the verification key the notification's secret
+	// matched, e.g. to tell when SePay has switched to a rotated key.
+	KeyID string
+}
+
+// DeliveryID returns the identifier used to detect redeliveries of the
+// notification: its type plus the most specific ID it carries (transaction,
+// agreement, then order). Notifications without any ID fall back to a hash
+// of the body.
+func (e *WebhookEvent) DeliveryID() string {
+	var ref string
+	switch {
+	case e.Transaction != nil && e.Transaction.ID != "":
+		ref = e.Transaction.ID
+	case e.Transaction != nil && e.Transaction.TransactionID != "":
+		ref = e.Transaction.TransactionID
+	case e.Agreement != nil && e.Agreement.AgreementID != "":
+		ref = e.Agreement.AgreementID
+	case e.Order != nil && e.Order.ID != "":
+		ref = e.Order.ID
+	case e.Order != nil && e.Order.OrderInvoiceNumber != "":
+		ref = e.Order.OrderInvoiceNumber
+	default:
+		sum := sha256.Sum256(e.Raw)
+		ref = hex.EncodeToString(sum[:])
+	}
+	return string(e.Type) + ":" + ref
+}
+
+// WebhookCustomer identifies the customer a notification refers to.
+type WebhookCustomer struct {
+	ID         string
+	CustomerID string
+}
+
+// ParseWebhookEvent decodes a notification body. It does not verify the
+// notification; use WebhookHandler for that.
+func ParseWebhookEvent(body []byte) (*WebhookEvent, error) {
+	var raw struct {
+		Timestamp        json.RawMessage `json:"timestamp"`
+		NotificationType EventType       `json:"notification_type"`
+		Merchant         string          `json:"merchant"`
+		Order            *Order          `json:"order"`
+		Transaction      *Transaction    `json:"transaction"`
+		Customer         *struct {
+			ID         flexString `json:"id"`
+			CustomerID flexString `json:"customer_id"`
+		} `json:"customer"`
+		Agreement json.RawMessage `json:"agreement"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("sepay: decoding webhook event: %w", err)
+	}
+	if raw.NotificationType == "" {
+		return nil, errors.New("sepay: decoding webhook event: missing notification_type")
+	}
+
+	timestamp, err := parseWebhookTimestamp(raw.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	event := &WebhookEvent{
+		Type:        raw.NotificationType,
+		Merchant:    raw.Merchant,
+		Timestamp:   timestamp,
+		Order:       raw.Order,
+		Transaction: raw.Transaction,
+		Raw:         json.RawMessage(body),
+	}
+	if raw.Customer != nil {
+		event.Customer = &WebhookCustomer{
+			ID:         string(raw.Customer.ID),
+			CustomerID: string(raw.Customer.CustomerID),
+		}
+	}
+	if len(raw.Agreement) > 0 && string(raw.Agreement) != "null" {
+		currency := ""
+		if raw.Order != nil {
+			currency = raw.Order.OrderCurrency
+		}
+		event.Agreement = &Agreement{AmountPerPayment: NewAmount(0, currency)}
+		if err := json.Unmarshal(raw.Agreement, event.Agreement); err != nil {
+			return nil, fmt.Errorf("sepay: decoding webhook agreement: %w", err)
+		}
+	}
+	return event, nil
+}
+
+// parseWebhookTimestamp accepts a Unix timestamp in seconds or a timestamp
+// string in the SePay layout.
+func parseWebhookTimestamp(raw json.RawMessage) (time.Time, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return time.Time{}, nil
+	}
+	var s flexString
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return time.Time{}, fmt.Errorf("sepay: decoding webhook timestamp: %w", err)
+	}
+	if unix, err := strconv.ParseInt(string(s), 10, 64); err == nil {
+		return time.Unix(unix, 0), nil
+	}
+	return parseTime(string(s))
+}
+
+// WebhookHandlerFunc handles a verified notification. Returning an error
+// makes the WebhookHandler respond with a failure so SePay delivers the
+// notification again.
+type WebhookHandlerFunc func(ctx context.Context, event *WebhookEvent) error
+
+// WebhookHandler is an http.Handler that receives instant payment
+// notifications. It limits the body size, verifies the merchant secret key
+// in constant time, decodes the event and dispatches it to the function
+// registered for its type. Notifications without a registered function are
+// acknowledged and dropped.
+//
+// When Deliveries is set, every delivery is recorded there and redeliveries
+// are dispatched with WebhookEvent.Duplicate set, so handlers can skip work
+// they have already done.
+type WebhookHandler struct {
+	// MaxBodyBytes limits the size of notification bodies. Defaults to 1 MiB.
+	MaxBodyBytes int64
+	// Deliveries, if set, records deliveries to detect duplicates.
+	Deliveries DeliveryStore
+	// MaxEventAge, if positive, marks notifications whose timestamp is
+	// missing or older than this as stale. Stale redeliveries that
+	// Deliveries has already recorded are acknowledged without being
+	// dispatched. Other stale notifications, e.g. a first delivery SePay
+	// kept retrying through an outage, go to OnStale, or to the function
+	// registered for their type if OnStale is nil.
+	MaxEventAge time.Duration
+	// OnStale, if set, handles the stale notifications that are not known
+	// redeliveries, e.g. to confirm the order through the API before
+	// acting on it.
+	OnStale WebhookHandlerFunc
+
+	// keys resolves the verification keys of the merchant a notification
+	// is addressed to.
+	keys func(ctx context.Context, merchant string) ([]VerificationKey, error)
+	now  func() time.Time
+
+	hooks    []Hooks
+	mu       sync.RWMutex
+	handlers map[EventType]WebhookHandlerFunc
+}
+
+// NewWebhookHandler returns a WebhookHandler that verifies notifications
+// against the given merchant secret key, reported with the key ID "active".
+func NewWebhookHandler(secretKey string) *WebhookHandler {
+	keys := []VerificationKey{{ID: defaultSecretKeyID, Secret: secretKey}}
+	return newWebhookHandler(func(context.Context, string) ([]VerificationKey, error) {
+		return keys, nil
+	})
+}
+
+func newWebhookHandler(keys func(ctx context.Context, merchant string) ([]VerificationKey, error)) *WebhookHandler {
+	return &WebhookHandler{
+		keys:     keys,
+		now:      time.Now,
+		handlers: make(map[EventType]WebhookHandlerFunc),
+	}
+}
+
+// NewWebhookHandler returns a WebhookHandler that verifies notifications
+// against the client's secret key and its unexpired previous keys.
+func (c *Client) NewWebhookHandler() *WebhookHandler {
+	h := newWebhookHandler(func(ctx context.Context, _ string) ([]VerificationKey, error) {
+		return c.config.verificationKeys(ctx)
+	})
+	h.hooks = append(h.hooks, c.hooks...)
+	return h
+}
+
+// On registers fn as the handler for events of the given type, replacing any
+// previously registered handler.
+func (h *WebhookHandler) On(eventType EventType, fn WebhookHandlerFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handlers[eventType] = fn
+}
+
+// webhookAck is the acknowledgement body SePay expects.
+type webhookAck struct {
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+}
+
+func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if len(h.hooks) == 0 {
+		h.serve(w, r)
+		return
+	}
+	start := time.Now()
+	r = r.WithContext(h.webhookStart(r.Context(), r))
+	result := h.serve(w, r)
+	result.Duration = time.Since(start)
+	h.webhookDone(r.Context(), result)
+}
+
+// serve handles a notification and reports how.
+func (h *WebhookHandler) serve(w http.ResponseWriter, r *http.Request) WebhookResult {
+	respond := func(status int, outcome WebhookOutcome, message string) WebhookResult {
+		writeWebhookAck(w, status, message)
+		return WebhookResult{Outcome: outcome, StatusCode: status}
+	}
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		return respond(http.StatusMethodNotAllowed, WebhookMethodNotAllowed, "method not allowed")
+	}
+
+	maxBytes := h.MaxBodyBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxWebhookBodyBytes
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return respond(http.StatusRequestEntityTooLarge, WebhookInvalidRequest, "body too large")
+		}
+		return respond(http.StatusBadRequest, WebhookInvalidRequest, "unreadable body")
+	}
+
+	now := h.now()
+	keyID, err := h.verify(r, body, now)
+	if err != nil {
+		return respond(http.StatusUnauthorized, WebhookUnauthorized, "unauthorized")
+	}
+
+	event, err := ParseWebhookEvent(body)
+	if err != nil {
+		return respond(http.StatusBadRequest, WebhookInvalidPayload, "invalid payload")
+	}
+	event.KeyID = keyID
+
+	result := func(status int, outcome WebhookOutcome, message string) WebhookResult {
+		res := respond(status, outcome, message)
+		res.Event = event
+		return res
+	}
+
+	stale := h.MaxEventAge > 0 && (event.Timestamp.IsZero() || now.Sub(event.Timestamp) > h.MaxEventAge)
+
+	ctx := r.Context()
+	if h.Deliveries != nil {
+		duplicate, err := h.Deliveries.Record(ctx, event.DeliveryID(), now)
+		if err != nil {
+			return result(http.StatusInternalServerError, WebhookStoreError, "delivery store error")
+		}
+		event.Duplicate = duplicate
+	}
+	if stale && event.Duplicate {
+		return result(http.StatusOK, WebhookStale, "stale event")
+	}
+
+	h.mu.RLock()
+	fn := h.handlers[event.Type]
+	h.mu.RUnlock()
+	outcome := WebhookAccepted
+	if stale && h.OnStale != nil {
+		fn = h.OnStale
+		outcome = WebhookStale
+	}
+	if fn != nil {
+		if err := fn(ctx, event); err != nil {
+			// Let the redelivery be processed as new.
+			if h.Deliveries != nil && !event.Duplicate {
+				h.Deliveries.Forget(ctx, event.DeliveryID())
+			}
+			res := result(http.StatusInternalServerError, WebhookHandlerError, "handler error")
+			res.Err = err
+			return res
+		}
+	}
+
+	return result(http.StatusOK, outcome, "")
+}
+
+// verify checks that the request carries a secret key of the merchant the
+// notification is addressed to, and returns the ID of the key it matched.
+func (h *WebhookHandler) verify(r *http.Request, body []byte, now time.Time) (string, error) {
+	provided := r.Header.Get(WebhookSecretHeader)
+	if provided == "" {
+		return "", ErrInvalidWebhookSecret
+	}
+
+	// Only the merchant is read before verification, to pick the keys.
+	var addressee struct {
+		Merchant string `json:"merchant"`
+	}
+	json.Unmarshal(body, &addressee)
+
+	keys, err := h.keys(r.Context(), addressee.Merchant)
+	if err != nil {
+		return "", ErrInvalidWebhookSecret
+	}
+	keyID, ok := matchKey(keys, now, provided, func(secret string) string { return secret })
+	if !ok {
+		return "", ErrInvalidWebhookSecret
+	}
+	return keyID, nil
+}
+
+func writeWebhookAck(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(webhookAck{Success: status == http.StatusOK, Message: message})
+}
@@ -0,0 +1,195 @@
+package sepay
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DeliveryStore records the notifications a WebhookHandler has processed, so
+// that redeliveries of the same notification can be detected. Implementations
+// must be safe for concurrent use.
+//
+// Deliveries only need to be kept for as long as SePay may redeliver them,
+// so the stores in this package can be purged periodically:
+//
+//	store.Purge(ctx, time.Now().Add(-7*24*time.Hour))
+//
+// A purged delivery is handled like a first delivery if it arrives again, so
+// keep deliveries well beyond WebhookHandler.MaxEventAge.
+type DeliveryStore interface {
+	// Record atomically records the delivery with the given ID and reports
+	// whether it had already been recorded.
+	Record(ctx context.Context, id string, receivedAt time.Time) (duplicate bool, err error)
+	// Forget removes the delivery with the given ID, so that a redelivery is
+	// processed again. It is called when the event handler fails.
+	Forget(ctx context.Context, id string) error
+}
+
+// MemoryDeliveryStore is an in-memory DeliveryStore that keeps the most
+// recently recorded deliveries, evicting the least recently used ones beyond
+// its capacity. It does not survive restarts and is not shared between
+// processes.
+type MemoryDeliveryStore struct {
+	capacity int
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// memoryDelivery is an entry of a MemoryDeliveryStore.
+type memoryDelivery struct {
+	id         string
+	receivedAt time.Time
+}
+
+// NewMemoryDeliveryStore returns a MemoryDeliveryStore holding at most
+// capacity deliveries.
+func NewMemoryDeliveryStore(capacity int) *MemoryDeliveryStore {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &MemoryDeliveryStore{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// Record implements DeliveryStore.
+func (s *MemoryDeliveryStore) Record(ctx context.Context, id string, receivedAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[id]; ok {
+		s.order.MoveToFront(e)
+		return true, nil
+	}
+	s.entries[id] = s.order.PushFront(memoryDelivery{id: id, receivedAt: receivedAt})
+	if s.order.Len() > s.capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(memoryDelivery).id)
+	}
+	return false, nil
+}
+
+// Forget implements DeliveryStore.
+func (s *MemoryDeliveryStore) Forget(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[id]; ok {
+		s.order.Remove(e)
+		delete(s.entries, id)
+	}
+	return nil
+}
+
+// Purge removes the deliveries first received before the given time and
+// returns how many were removed.
+func (s *MemoryDeliveryStore) Purge(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	for e := s.order.Front(); e != nil; {
+		next := e.Next()
+		if d := e.Value.(memoryDelivery); d.receivedAt.Before(before) {
+			s.order.Remove(e)
+			delete(s.entries, d.id)
+			n++
+		}
+		e = next
+	}
+	return n, nil
+}
+
+// PlaceholderFormat is the bind parameter syntax of a SQL driver.
+type PlaceholderFormat int
+
+const (
+	// QuestionPlaceholders uses "?" (MySQL, SQLite).
+	QuestionPlaceholders PlaceholderFormat = iota
+	// DollarPlaceholders uses "$1", "$2", ... (PostgreSQL).
+	DollarPlaceholders
+)
+
+func (f PlaceholderFormat) placeholder(n int) string {
+	if f == DollarPlaceholders {
+		return fmt.Sprintf("$%d", n)
+	}
+	return "?"
+}
+
+// SQLDeliveryStore is a DeliveryStore backed by a database/sql table, shared
+// by every process using the same database. The table must have a unique
+// delivery ID column, e.g.:
+//
+//	CREATE TABLE sepay_webhook_deliveries (
+//		id          VARCHAR(255) PRIMARY KEY,
+//		received_at TIMESTAMP NOT NULL
+//	);
+type SQLDeliveryStore struct {
+	db    *sql.DB
+	table string
+
+	// Placeholders is the bind parameter syntax of the driver. Defaults to
+	// QuestionPlaceholders.
+	Placeholders PlaceholderFormat
+}
+
+// NewSQLDeliveryStore returns a SQLDeliveryStore using the given table. The
+// table name is interpolated into queries and must not come from user input.
+func NewSQLDeliveryStore(db *sql.DB, table string) *SQLDeliveryStore {
+	return &SQLDeliveryStore{db: db, table: table}
+}
+
+// Record implements DeliveryStore. It relies on the unique ID column: a failed
+// insert of an ID that is already present is reported as a duplicate.
+func (s *SQLDeliveryStore) Record(ctx context.Context, id string, receivedAt time.Time) (bool, error) {
+	insert := fmt.Sprintf("INSERT INTO %s (id, received_at) VALUES (%s, %s)",
+		s.table, s.Placeholders.placeholder(1), s.Placeholders.placeholder(2))
+	_, insertErr := s.db.ExecContext(ctx, insert, id, receivedAt.UTC())
+	if insertErr == nil {
+		return false, nil
+	}
+
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE id = %s", s.table, s.Placeholders.placeholder(1))
+	var count int
+	if err := s.db.QueryRowContext(ctx, query, id).Scan(&count); err != nil {
+		return false, fmt.Errorf("sepay: recording webhook delivery: %w", insertErr)
+	}
+	if count == 0 {
+		return false, fmt.Errorf("sepay: recording webhook delivery: %w", insertErr)
+	}
+	return true, nil
+}
+
+// Forget implements DeliveryStore.
+func (s *SQLDeliveryStore) Forget(ctx context.Context, id string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = %s", s.table, s.Placeholders.placeholder(1))
+	if _, err := s.db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("sepay: forgetting webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// Purge deletes the deliveries received before the given time and returns
+// how many were deleted. An index on received_at keeps it cheap.
+func (s *SQLDeliveryStore) Purge(ctx context.Context, before time.Time) (int64, error) {
+	query := fmt.Sprintf("DELETE FROM %s WHERE received_at < %s", s.table, s.Placeholders.placeholder(1))
+	res, err := s.db.ExecContext(ctx, query, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("sepay: purging webhook deliveries: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("sepay: purging webhook deliveries: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,328 @@
+package sepay
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryDeliveryStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryDeliveryStore(2)
+	now := time.Now()
+
+	record := func(id string) bool {
+		t.Helper()
+		dup, err := s.Record(ctx, id, now)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return dup
+	}
+
+	if record("a") {
+		t.Error("expected first delivery of a to be new")
+	}
+	if !record("a") {
+		t.Error("expected second delivery of a to be a duplicate")
+	}
+	record("b")
+	record("a") // a is now the most recently used
+	record("c") // evicts b
+	if record("b") {
+		t.Error("expected b to have been evicted")
+	}
+
+	s.Forget(ctx, "b")
+	if record("b") {
+		t.Error("expected forgotten b to be new")
+	}
+
+	old := NewMemoryDeliveryStore(10)
+	old.Record(ctx, "old", now.Add(-2*time.Hour))
+	old.Record(ctx, "new", now)
+	if n, err := old.Purge(ctx, now.Add(-time.Hour)); err != nil || n != 1 {
+		t.Fatalf("expected 1 purged delivery, got %d (%v)", n, err)
+	}
+	if dup, _ := old.Record(ctx, "old", now); dup {
+		t.Error("expected purged delivery to be new")
+	}
+	if dup, _ := old.Record(ctx, "new", now); !dup {
+		t.Error("expected recent delivery to be kept")
+	}
+}
+
+// fakeSQLTable is a minimal database/sql driver backing a single table with a
+// unique id column, enough to exercise SQLDeliveryStore.
+type fakeSQLTable struct {
+	mu      sync.Mutex
+	rows    map[string]time.Time
+	queries []string
+}
+
+func (f *fakeSQLTable) Connect(context.Context) (driver.Conn, error) { return &fakeSQLConn{f}, nil }
+func (f *fakeSQLTable) Driver() driver.Driver                        { return nil }
+
+type fakeSQLConn struct{ table *fakeSQLTable }
+
+func (c *fakeSQLConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeSQLStmt{table: c.table, query: query}, nil
+}
+func (c *fakeSQLConn) Close() error              { return nil }
+func (c *fakeSQLConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+type fakeSQLStmt struct {
+	table *fakeSQLTable
+	query string
+}
+
+func (s *fakeSQLStmt) Close() error  { return nil }
+func (s *fakeSQLStmt) NumInput() int { return -1 }
+
+func (s *fakeSQLStmt) Exec(args []driver.Value) (driver.Result, error) {
+	f := s.table
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queries = append(f.queries, s.query)
+
+	if strings.Contains(s.query, "received_at <") {
+		var n int64
+		for id, receivedAt := range f.rows {
+			if receivedAt.Before(args[0].(time.Time)) {
+				delete(f.rows, id)
+				n++
+			}
+		}
+		return driver.RowsAffected(n), nil
+	}
+
+	id := args[0].(string)
+	switch {
+	case strings.HasPrefix(s.query, "INSERT"):
+		if _, ok := f.rows[id]; ok {
+			return nil, errors.New("UNIQUE constraint failed: id")
+		}
+		f.rows[id] = args[1].(time.Time)
+	case strings.HasPrefix(s.query, "DELETE"):
+		delete(f.rows, id)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeSQLStmt) Query(args []driver.Value) (driver.Rows, error) {
+	f := s.table
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queries = append(f.queries, s.query)
+
+	var count int64
+	if _, ok := f.rows[args[0].(string)]; ok {
+		count = 1
+	}
+	return &fakeSQLRows{values: []driver.Value{count}}, nil
+}
+
+type fakeSQLRows struct {
+	values []driver.Value
+	done   bool
+}
+
+func (r *fakeSQLRows) Columns() []string { return []string{"count"} }
+func (r *fakeSQLRows) Close() error      { return nil }
+func (r *fakeSQLRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	copy(dest, r.values)
+	return nil
+}
+
+func TestSQLDeliveryStore(t *testing.T) {
+	ctx := context.Background()
+	table := &fakeSQLTable{rows: make(map[string]time.Time)}
+	db := sql.OpenDB(table)
+	defer db.Close()
+
+	s := NewSQLDeliveryStore(db, "sepay_webhook_deliveries")
+	s.Placeholders = DollarPlaceholders
+
+	dup, err := s.Record(ctx, "ORDER_PAID:tx_1", time.Now())
+	if err != nil || dup {
+		t.Fatalf("expected new delivery, got dup=%v err=%v", dup, err)
+	}
+	dup, err = s.Record(ctx, "ORDER_PAID:tx_1", time.Now())
+	if err != nil || !dup {
+		t.Fatalf("expected duplicate delivery, got dup=%v err=%v", dup, err)
+	}
+	if err := s.Forget(ctx, "ORDER_PAID:tx_1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dup, err = s.Record(ctx, "ORDER_PAID:tx_1", time.Now())
+	if err != nil || dup {
+		t.Fatalf("expected forgotten delivery to be new, got dup=%v err=%v", dup, err)
+	}
+
+	expected := "INSERT INTO sepay_webhook_deliveries (id, received_at) VALUES ($1, $2)"
+	if table.queries[0] != expected {
+		t.Errorf("expected query %q, got %q", expected, table.queries[0])
+	}
+
+	s.Record(ctx, "ORDER_PAID:tx_old", time.Now().Add(-48*time.Hour))
+	n, err := s.Purge(ctx, time.Now().Add(-24*time.Hour))
+	if err != nil || n != 1 {
+		t.Fatalf("expected 1 purged delivery, got %d (%v)", n, err)
+	}
+	if _, ok := table.rows["ORDER_PAID:tx_1"]; !ok {
+		t.Error("expected recent delivery to be kept")
+	}
+	expected = "DELETE FROM sepay_webhook_deliveries WHERE received_at < $1"
+	if last := table.queries[len(table.queries)-1]; last != expected {
+		t.Errorf("expected query %q, got %q", expected, last)
+	}
+}
+
+func TestWebhookHandler_Deliveries(t *testing.T) {
+	newHandler := func(fn WebhookHandlerFunc) *WebhookHandler {
+		h := NewWebhookHandler("secret456")
+		h.Deliveries = NewMemoryDeliveryStore(100)
+		h.On(EventOrderPaid, fn)
+		return h
+	}
+
+	t.Run("duplicate is flagged", func(t *testing.T) {
+		var flags []bool
+		h := newHandler(func(ctx context.Context, event *WebhookEvent) error {
+			flags = append(flags, event.Duplicate)
+			return nil
+		})
+		for i := 0; i < 2; i++ {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, newWebhookRequest(testOrderPaidPayload, "secret456"))
+			if rec.Code != 200 {
+				t.Fatalf("expected status 200, got %d", rec.Code)
+			}
+		}
+		if len(flags) != 2 || flags[0] || !flags[1] {
+			t.Errorf("expected [false true], got %v", flags)
+		}
+	})
+
+	t.Run("failed delivery is forgotten", func(t *testing.T) {
+		var calls int
+		var flags []bool
+		h := newHandler(func(ctx context.Context, event *WebhookEvent) error {
+			calls++
+			flags = append(flags, event.Duplicate)
+			if calls == 1 {
+				return errors.New("temporary failure")
+			}
+			return nil
+		})
+		for i := 0; i < 2; i++ {
+			h.ServeHTTP(httptest.NewRecorder(), newWebhookRequest(testOrderPaidPayload, "secret456"))
+		}
+		if len(flags) != 2 || flags[1] {
+			t.Errorf("expected redelivery after failure not to be a duplicate, got %v", flags)
+		}
+	})
+
+	t.Run("stale redelivery is acknowledged without dispatch", func(t *testing.T) {
+		var calls int
+		h := newHandler(func(ctx context.Context, event *WebhookEvent) error {
+			calls++
+			return nil
+		})
+		h.MaxEventAge = time.Hour
+		now := time.Unix(1709262960, 0).Add(time.Minute)
+		h.now = func() time.Time { return now }
+
+		h.ServeHTTP(httptest.NewRecorder(), newWebhookRequest(testOrderPaidPayload, "secret456"))
+		now = now.Add(2 * time.Hour)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, newWebhookRequest(testOrderPaidPayload, "secret456"))
+		if rec.Code != 200 {
+			t.Errorf("expected status 200, got %d", rec.Code)
+		}
+		if calls != 1 {
+			t.Errorf("expected only the fresh delivery to be dispatched, got %d calls", calls)
+		}
+	})
+
+	t.Run("stale first delivery is dispatched", func(t *testing.T) {
+		var called bool
+		h := newHandler(func(ctx context.Context, event *WebhookEvent) error {
+			called = true
+			return nil
+		})
+		h.MaxEventAge = time.Hour
+		h.now = func() time.Time { return time.Unix(1709262960, 0).Add(2 * time.Hour) }
+
+		h.ServeHTTP(httptest.NewRecorder(), newWebhookRequest(testOrderPaidPayload, "secret456"))
+		if !called {
+			t.Error("expected an unrecorded stale event not to be dropped")
+		}
+	})
+
+	t.Run("stale first delivery goes to OnStale", func(t *testing.T) {
+		h := newHandler(func(ctx context.Context, event *WebhookEvent) error {
+			t.Error("unexpected dispatch to the event handler")
+			return nil
+		})
+		h.MaxEventAge = time.Hour
+		h.now = func() time.Time { return time.Unix(1709262960, 0).Add(2 * time.Hour) }
+		var stale []bool
+		h.OnStale = func(ctx context.Context, event *WebhookEvent) error {
+			stale = append(stale, event.Duplicate)
+			if len(stale) == 1 {
+				return errors.New("order lookup failed")
+			}
+			return nil
+		}
+
+		for _, want := range []int{500, 200} {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, newWebhookRequest(testOrderPaidPayload, "secret456"))
+			if rec.Code != want {
+				t.Errorf("expected status %d, got %d", want, rec.Code)
+			}
+		}
+		if len(stale) != 2 || stale[1] {
+			t.Errorf("expected the retry after a failure to reach OnStale again, got %v", stale)
+		}
+	})
+
+	t.Run("fresh event is accepted", func(t *testing.T) {
+		var called bool
+		h := newHandler(func(ctx context.Context, event *WebhookEvent) error {
+			called = true
+			return nil
+		})
+		h.MaxEventAge = time.Hour
+		h.now = func() time.Time { return time.Unix(1709262960, 0).Add(time.Minute) }
+
+		h.ServeHTTP(httptest.NewRecorder(), newWebhookRequest(testOrderPaidPayload, "secret456"))
+		if !called {
+			t.Error("expected dispatch")
+		}
+	})
+}
+
+func TestWebhookEvent_DeliveryID(t *testing.T) {
+	event, _ := ParseWebhookEvent([]byte(testOrderPaidPayload))
+	if id := event.DeliveryID(); id != "ORDER_PAID:384c66dd-41e6-4316-a544-b4141682595c" {
+		t.Errorf("unexpected delivery ID %q", id)
+	}
+
+	cancelled, _ := ParseWebhookEvent([]byte(`{"notification_type":"ORDER_CANCELLED","order":{"order_invoice_number":"INV-001"}}`))
+	if id := cancelled.DeliveryID(); id != "ORDER_CANCELLED:INV-001" {
+		t.Errorf("unexpected delivery ID %q", id)
+	}
+}
@@ -0,0 +1,188 @@
+package sepay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testOrderPaidPayload = `{
+	"timestamp": 1709262960,
+	"notification_type": "ORDER_PAID",
+	"order": {
+		"id": "e2c195be-c721-47eb-b323-99ab24e52d85",
+		"order_id": "NQD-68DA43D73C1A5",
+		"order_status": "CAPTURED",
+		"order_currency": "VND",
+		"order_amount": "100000.00",
+		"order_invoice_number": "INV-001",
+		"custom_data": [],
+		"order_description": "Test payment"
+	},
+	"transaction": {
+		"id": "384c66dd-41e6-4316-a544-b4141682595c",
+		"payment_method": "BANK_TRANSFER",
+		"transaction_id": "68da43da2d9de",
+		"transaction_type": "PAYMENT",
+		"transaction_date": "2024-03-01 10:16:00",
+		"transaction_status": "APPROVED",
+		"transaction_amount": "100000",
+		"transaction_currency": "VND"
+	},
+	"customer": {
+		"id": "fb5b9e5f-2a56-4b5e-8e4f-2c2b6e1c3b9e",
+		"customer_id": "CUST-001"
+	}
+}`
+
+func newWebhookRequest(body, secret string) *http.Request {
+	req := httptest.NewRequest("POST", "/ipn", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		req.Header.Set(WebhookSecretHeader, secret)
+	}
+	return req
+}
+
+func TestParseWebhookEvent(t *testing.T) {
+	event, err := ParseWebhookEvent([]byte(testOrderPaidPayload))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if event.Type != EventOrderPaid {
+		t.Errorf("expected type %q, got %q", EventOrderPaid, event.Type)
+	}
+	if !event.Timestamp.Equal(time.Unix(1709262960, 0)) {
+		t.Errorf("unexpected timestamp %v", event.Timestamp)
+	}
+	if event.Order == nil || event.Order.OrderAmount != VND(100000) {
+		t.Errorf("unexpected order %+v", event.Order)
+	}
+	if event.Transaction == nil || event.Transaction.TransactionID != "68da43da2d9de" {
+		t.Errorf("unexpected transaction %+v", event.Transaction)
+	}
+	if event.Customer == nil || event.Customer.CustomerID != "CUST-001" {
+		t.Errorf("unexpected customer %+v", event.Customer)
+	}
+	if event.Agreement != nil {
+		t.Errorf("expected no agreement, got %+v", event.Agreement)
+	}
+
+	t.Run("agreement event", func(t *testing.T) {
+		event, err := ParseWebhookEvent([]byte(`{
+			"timestamp": "2024-03-01 10:16:00",
+			"notification_type": "AGREEMENT_CREATED",
+			"order": {"order_currency": "USD"},
+			"agreement": {"agreement_id": "AGR-001", "agreement_amount_per_payment": "9.90"}
+		}`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if event.Agreement == nil || event.Agreement.AmountPerPayment != NewAmount(990, "USD") {
+			t.Errorf("unexpected agreement %+v", event.Agreement)
+		}
+		if !event.Timestamp.Equal(time.Date(2024, 3, 1, 3, 16, 0, 0, time.UTC)) {
+			t.Errorf("unexpected timestamp %v", event.Timestamp)
+		}
+	})
+
+	t.Run("missing type", func(t *testing.T) {
+		if _, err := ParseWebhookEvent([]byte(`{"order":{}}`)); err == nil {
+			t.Error("expected error for missing notification_type")
+		}
+	})
+}
+
+func TestWebhookHandler(t *testing.T) {
+	t.Run("dispatches verified event", func(t *testing.T) {
+		h := NewWebhookHandler("secret456")
+		var got *WebhookEvent
+		h.On(EventOrderPaid, func(ctx context.Context, event *WebhookEvent) error {
+			got = event
+			return nil
+		})
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, newWebhookRequest(testOrderPaidPayload, "secret456"))
+
+		if rec.Code != 200 {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		var ack webhookAck
+		json.Unmarshal(rec.Body.Bytes(), &ack)
+		if !ack.Success {
+			t.Errorf("expected success acknowledgement, got %s", rec.Body.String())
+		}
+		if got == nil || got.Order.OrderInvoiceNumber != "INV-001" {
+			t.Errorf("expected handler to receive event, got %+v", got)
+		}
+	})
+
+	t.Run("client handler uses client secret", func(t *testing.T) {
+		c, _ := NewClient(Config{Env: Sandbox, MerchantID: "merchant123", SecretKey: "secret456"})
+		rec := httptest.NewRecorder()
+		c.NewWebhookHandler().ServeHTTP(rec, newWebhookRequest(testOrderPaidPayload, "secret456"))
+		if rec.Code != 200 {
+			t.Errorf("expected status 200, got %d", rec.Code)
+		}
+	})
+
+	t.Run("unhandled event is acknowledged", func(t *testing.T) {
+		h := NewWebhookHandler("secret456")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, newWebhookRequest(`{"notification_type":"SOMETHING_NEW"}`, "secret456"))
+		if rec.Code != 200 {
+			t.Errorf("expected status 200, got %d", rec.Code)
+		}
+	})
+
+	failures := []struct {
+		name    string
+		method  string
+		body    string
+		secret  string
+		maxBody int64
+		status  int
+	}{
+		{"missing secret", "POST", testOrderPaidPayload, "", 0, 401},
+		{"wrong secret", "POST", testOrderPaidPayload, "secret457", 0, 401},
+		{"wrong method", "GET", testOrderPaidPayload, "secret456", 0, 405},
+		{"invalid JSON", "POST", `{"notification_type":`, "secret456", 0, 400},
+		{"body too large", "POST", testOrderPaidPayload, "secret456", 64, 413},
+	}
+	for _, tc := range failures {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewWebhookHandler("secret456")
+			h.MaxBodyBytes = tc.maxBody
+			h.On(EventOrderPaid, func(ctx context.Context, event *WebhookEvent) error {
+				t.Error("unexpected dispatch")
+				return nil
+			})
+			req := newWebhookRequest(tc.body, tc.secret)
+			req.Method = tc.method
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tc.status {
+				t.Errorf("expected status %d, got %d", tc.status, rec.Code)
+			}
+		})
+	}
+
+	t.Run("handler error asks for redelivery", func(t *testing.T) {
+		h := NewWebhookHandler("secret456")
+		h.On(EventOrderPaid, func(ctx context.Context, event *WebhookEvent) error {
+			return errors.New("fulfillment failed")
+		})
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, newWebhookRequest(testOrderPaidPayload, "secret456"))
+		if rec.Code != 500 {
+			t.Errorf("expected status 500, got %d", rec.Code)
+		}
+	})
+}